@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/robfig/cron/v3"
+)
+
+// Telegram rejects messages that are longer than this
+const maxMessageLength int = 4096
+
+var adminCommands = map[string]bool{"stats": true, "broadcast": true, "jobs": true, "user": true}
+
+// Names of the registration states, for admins inspecting a user
+var userStateNames = map[int]string{
+	1: "choosing a bus service",
+	2: "choosing a bus stop",
+	3: "choosing days",
+	4: "choosing a time",
+	5: "deleting an alarm",
+	6: "confirming an import",
+}
+
+// isAdminUpdate returns true if the update is an admin command from an admin chat.
+// Admin commands from other chats are treated like any other message, so that they are not revealed
+func isAdminUpdate(update tgbotapi.Update) bool {
+	message := update.Message
+	return message != nil && message.IsCommand() && adminCommands[message.Command()] && config.IsAdmin(message.Chat.ID)
+}
+
+// handleAdminCommand answers the admin commands, which let operators see what the bot is doing
+func handleAdminCommand(update tgbotapi.Update) registrationReply {
+	message := update.Message
+	chatID := message.Chat.ID
+	arguments := strings.TrimSpace(message.CommandArguments())
+	logInfo("Admin command", "chat_id", chatID, "command", message.Command())
+
+	var text string
+	var err error
+	switch message.Command() {
+	case "stats":
+		text, err = statsMessage()
+	case "broadcast":
+		text, err = broadcast(arguments)
+	case "jobs":
+		if arguments != "today" {
+			text = "Usage: /jobs today"
+		} else {
+			text = todayJobsMessage(cronner.Entries())
+		}
+	case "user":
+		userChatID, parseErr := strconv.ParseInt(arguments, 10, 64)
+		if parseErr != nil {
+			text = "Usage: /user <chatID>"
+		} else {
+			text, err = userMessage(userChatID)
+		}
+	}
+	if err != nil {
+		return errorReply(chatID, err)
+	}
+	return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, truncateMessage(text))}
+}
+
+// statsMessage summarises the users and alarms, and what has happened today
+func statsMessage() (string, error) {
+	// Bad entries are skipped, so that the stats of the rest of the alarms are still shown
+	jobs, err := storedJobDB.GetAllJobs()
+	if err != nil {
+		logWarn("Unable to load all jobs for stats", "err", err)
+	}
+
+	users := make(map[int64]bool)
+	alarmsPerDay := make(map[time.Weekday]int)
+	for _, job := range jobs {
+		users[job.ChatID] = true
+		for _, weekday := range job.Weekdays {
+			alarmsPerDay[weekday]++
+		}
+	}
+
+	stats := todayStats.Snapshot()
+	stringBuilder := strings.Builder{}
+	fmt.Fprintf(&stringBuilder, "Users with alarms: %d\n", len(users))
+	fmt.Fprintf(&stringBuilder, "Alarms: %d\n", len(jobs))
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		fmt.Fprintf(&stringBuilder, "  %s: %d\n", weekday, alarmsPerDay[weekday])
+	}
+	stringBuilder.WriteString("\nToday\n")
+	fmt.Fprintf(&stringBuilder, "Alarms fired: %d\n", stats.alarmsFired)
+	fmt.Fprintf(&stringBuilder, "LTA DataMall errors: %d\n", stats.datamallErrors)
+	fmt.Fprintf(&stringBuilder, "Telegram errors: %d\n", stats.telegramErrors)
+	fmt.Fprintf(&stringBuilder, "\nOutgoing queue: %d alarms, %d replies", outgoingMessages.Depth(priorityAlarm), outgoingMessages.Depth(priorityReply))
+	return stringBuilder.String(), nil
+}
+
+// broadcast sends the text to every user with an alarm.
+// The messages are queued in the background, so that updates are still handled while they are sent
+func broadcast(text string) (string, error) {
+	if text == "" {
+		return "Usage: /broadcast <text>", nil
+	}
+	jobs, err := storedJobDB.GetAllJobs()
+	if err != nil {
+		logWarn("Unable to load all jobs for broadcast", "err", err)
+	}
+
+	chatIDs := []int64{}
+	seen := make(map[int64]bool)
+	for _, job := range jobs {
+		if !seen[job.ChatID] {
+			seen[job.ChatID] = true
+			chatIDs = append(chatIDs, job.ChatID)
+		}
+	}
+
+	logInfo("Broadcasting", "chats", len(chatIDs))
+	go func() {
+		for _, chatID := range chatIDs {
+			outgoingMessages.Push(priorityReply, tgbotapi.NewMessage(chatID, text))
+		}
+	}()
+	return fmt.Sprintf("Broadcasting to %d chats", len(chatIDs)), nil
+}
+
+// todayJobsMessage lists the cron entries in the order that they run, with the owner of each bus alarm
+func todayJobsMessage(entries []cron.Entry) string {
+	stringBuilder := strings.Builder{}
+	fmt.Fprintf(&stringBuilder, "%d cron entries\n", len(entries))
+	for _, entry := range entries {
+		next := entry.Next.In(location).Format("Mon 15:04")
+		if alarm, ok := entry.Job.(alarmCronJob); ok {
+			fmt.Fprintf(&stringBuilder, "%s - alarm %d of chat %d - Bus %s @ %s\n", next, alarm.ID, alarm.ChatID, alarm.BusServiceNo, alarm.BusStopCode)
+		} else if snooze, ok := entry.Job.(snoozeCronJob); ok {
+			fmt.Fprintf(&stringBuilder, "%s - snooze of chat %d - Bus %s @ %s\n", next, snooze.ChatID, snooze.BusServiceNo, snooze.BusStopCode)
+		} else if entry.ID == refreshCronEntryID {
+			fmt.Fprintf(&stringBuilder, "%s - loading the next day's alarms\n", next)
+		} else {
+			fmt.Fprintf(&stringBuilder, "%s - entry %d\n", next, entry.ID)
+		}
+	}
+	return stringBuilder.String()
+}
+
+// userMessage shows the user's alarms, where they are in registration, and their preferences
+func userMessage(chatID int64) (string, error) {
+	jobs, err := storedJobDB.GetJobsByChatID(chatID)
+	if err != nil {
+		return "", err
+	}
+	userState, err := userStateDB.GetUserState(chatID)
+	if err != nil {
+		return "", err
+	}
+	preferences, err := preferencesDB.GetPreferences(chatID)
+	if err != nil {
+		return "", err
+	}
+
+	stringBuilder := strings.Builder{}
+	fmt.Fprintf(&stringBuilder, "Chat %d\n\nAlarms: %d\n", chatID, len(jobs))
+	for _, job := range jobs {
+		fmt.Fprintf(&stringBuilder, "%d. %s - %s - Bus %s @ %s\n", job.ID, joinDaysString(defaultLanguage, job.Weekdays), job.ScheduledTime.ToString(), job.BusServiceNo, job.BusStopCode)
+	}
+
+	stringBuilder.WriteString("\nRegistration: ")
+	if userState == nil {
+		stringBuilder.WriteString("not registering\n")
+	} else {
+		fmt.Fprintf(&stringBuilder, "state %d, %s\n", userState.State, userStateNames[userState.State])
+		fmt.Fprintf(&stringBuilder, "Bus: %s, stop: %s, days: %s\n", userState.BusServiceNo, userState.BusStopCode, joinDaysString(defaultLanguage, userState.GetSelectedDays()))
+	}
+
+	fmt.Fprintf(&stringBuilder, "\nSettings: %s layout, %s, bus stop description %s",
+		layoutName(defaultLanguage, preferences), timeName(defaultLanguage, preferences), strings.ToLower(descriptionName(defaultLanguage, preferences)))
+	return stringBuilder.String(), nil
+}
+
+// truncateMessage shortens the text to fit in a single Telegram message
+func truncateMessage(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return text
+	}
+	const ellipsis string = "\n…"
+	return string(runes[:maxMessageLength-len([]rune(ellipsis))]) + ellipsis
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/robfig/cron/v3"
+)
+
+func commandUpdate(chatID int64, text string) tgbotapi.Update {
+	command := strings.Fields(text)[0]
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:     &tgbotapi.Chat{ID: chatID},
+		Text:     text,
+		Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}}
+}
+
+func TestAdminCommandsAreOnlyForAdmins(t *testing.T) {
+	config = &Config{AdminChatIDs: []int64{1}}
+	defer func() { config = nil }()
+
+	if !isAdminUpdate(commandUpdate(1, "/stats")) {
+		t.Error("Expected /stats from an admin to be an admin command")
+	}
+	if isAdminUpdate(commandUpdate(2, "/stats")) {
+		t.Error("Expected /stats from another chat not to be an admin command")
+	}
+	if isAdminUpdate(commandUpdate(1, "/register")) {
+		t.Error("Expected /register not to be an admin command")
+	}
+}
+
+func TestUserMessage(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	storedJobDB, userStateDB, preferencesDB = memoryStore.Jobs, memoryStore.UserStates, memoryStore.Preferences
+	defer func() { storedJobDB, userStateDB, preferencesDB = nil, nil, nil }()
+
+	storedJobDB.StoreJob(BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}})
+	userState := UserState{State: 2, SelectedDays: make(map[time.Weekday]bool)}
+	userState.BusServiceNo = "61"
+	userStateDB.SaveUserState(12345, userState)
+
+	text, err := userMessage(12345)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Alarms: 1", "Monday - 07:30 - Bus 506 @ 43411", "state 2, choosing a bus stop", "Bus: 61"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in %s", expected, text)
+		}
+	}
+}
+
+func TestTodayJobsMessageShowsOwners(t *testing.T) {
+	testCronner := cron.New()
+	testCronner.AddJob("30 7 * * *", alarmCronJob{BusInfoJob{ID: 7, ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506"}})
+
+	text := todayJobsMessage(testCronner.Entries())
+	if !strings.Contains(text, "alarm 7 of chat 12345 - Bus 506 @ 43411") {
+		t.Errorf("Unexpected jobs message: %s", text)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	if truncateMessage("short") != "short" {
+		t.Error("Short messages should not be truncated")
+	}
+	if truncated := truncateMessage(strings.Repeat("🚌", maxMessageLength+1)); len([]rune(truncated)) != maxMessageLength {
+		t.Errorf("Expected %d characters but got %d", maxMessageLength, len([]rune(truncated)))
+	}
+}
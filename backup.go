@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Backup directories are named by the time the backup was taken, so they sort in the order they were taken
+const backupTimeFormat string = "20060102-150405"
+
+// Backup takes a hot copy of every database, in a read transaction so that the bot keeps running,
+// into a new timestamped directory within backupDir. Returns the path of the new directory
+func (s *Store) Backup(backupDir string) (string, error) {
+	if s.jobDB == nil {
+		return "", fmt.Errorf("In-memory store cannot be backed up")
+	}
+
+	backupPath := filepath.Join(backupDir, time.Now().Format(backupTimeFormat))
+	if err := os.MkdirAll(backupPath, 0700); err != nil {
+		return "", err
+	}
+
+	for _, db := range []*bolt.DB{s.jobDB, s.userStateDB, s.preferencesDB} {
+		backupFile := filepath.Join(backupPath, filepath.Base(db.Path()))
+		err := db.View(func(tx *bolt.Tx) error {
+			f, err := os.OpenFile(backupFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.WriteTo(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return backupPath, nil
+}
+
+// listBackups returns the backup directories within backupDir, oldest first
+func listBackups(backupDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []string{}
+	for _, entry := range entries {
+		if _, err := time.Parse(backupTimeFormat, entry.Name()); entry.IsDir() && err == nil {
+			backups = append(backups, filepath.Join(backupDir, entry.Name()))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups deletes all but the newest retain backups within backupDir
+func pruneBackups(backupDir string, retain int) error {
+	backups, err := listBackups(backupDir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(backups)-retain; i++ {
+		logInfo("Deleting old backup", "path", backups[i])
+		if err := os.RemoveAll(backups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupAndPrune takes a backup, then deletes old backups beyond retain
+func backupAndPrune(backupStore *Store, backupDir string, retain int) {
+	backupPath, err := backupStore.Backup(backupDir)
+	if err != nil {
+		logError("Unable to back up databases", "err", err)
+		return
+	}
+	logInfo("Backed up databases", "path", backupPath)
+
+	if err := pruneBackups(backupDir, retain); err != nil {
+		logError("Unable to delete old backups", "err", err)
+	}
+}
+
+// restoreBackup replaces each of the database files with its copy in backupPath.
+// The bot must not be running, the databases are opened first to make sure that nothing else holds them.
+// Every backup is checked and copied next to its database before any database is replaced,
+// so that the databases are not left from different backups when one of them cannot be restored
+func restoreBackup(backupPath string, dbFiles ...string) error {
+	restoreFiles := []string{}
+	removeRestoreFiles := func() {
+		for _, restoreFile := range restoreFiles {
+			os.Remove(restoreFile)
+		}
+	}
+
+	for _, dbFile := range dbFiles {
+		if err := prepareRestore(backupPath, dbFile); err != nil {
+			removeRestoreFiles()
+			os.Remove(dbFile + ".restore")
+			return err
+		}
+		restoreFiles = append(restoreFiles, dbFile+".restore")
+	}
+
+	for i, dbFile := range dbFiles {
+		if err := os.Rename(restoreFiles[i], dbFile); err != nil {
+			removeRestoreFiles()
+			return fmt.Errorf("Restored %d of %d databases before failing, restore the backup again: %v", i, len(dbFiles), err)
+		}
+		logInfo("Restored database", "db", dbFile, "backup", filepath.Join(backupPath, filepath.Base(dbFile)))
+	}
+	return nil
+}
+
+// prepareRestore checks that the backup of the database file is a bolt database, and that nothing holds the database file,
+// then copies the backup next to the database file with the .restore extension
+func prepareRestore(backupPath string, dbFile string) error {
+	backupFile := filepath.Join(backupPath, filepath.Base(dbFile))
+
+	// Make sure the backup is a bolt database before it replaces anything
+	backupDB, err := bolt.Open(backupFile, 0600, &bolt.Options{Timeout: storeOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("Unable to open backup %s: %v", backupFile, err)
+	}
+	backupDB.Close()
+
+	db, err := openBoltDB(dbFile)
+	if err != nil {
+		return fmt.Errorf("Unable to open %s, make sure the bot is stopped: %v", dbFile, err)
+	}
+	db.Close()
+
+	if err := copyFile(backupFile, dbFile+".restore"); err != nil {
+		return fmt.Errorf("Unable to copy backup %s: %v", backupFile, err)
+	}
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
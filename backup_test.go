@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	jobDBFile := filepath.Join(dir, "job.db")
+	userStateDBFile := filepath.Join(dir, "user_state.db")
+	preferencesDBFile := filepath.Join(dir, "preferences.db")
+
+	backupStore, err := OpenStore(jobDBFile, userStateDBFile, preferencesDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupStore.Jobs.StoreJob(BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}})
+
+	backupPath, err := backupStore.Backup(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lose the alarm after the backup
+	storedJobs, _ := backupStore.Jobs.GetJobsByChatID(12345)
+	backupStore.Jobs.DeleteJob(storedJobs[0].ID)
+	backupStore.Close()
+
+	if err := restoreBackup(backupPath, jobDBFile, userStateDBFile, preferencesDBFile); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredStore, err := OpenStore(jobDBFile, userStateDBFile, preferencesDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restoredStore.Close()
+	if restoredJobs, _ := restoredStore.Jobs.GetJobsByChatID(12345); len(restoredJobs) != 1 {
+		t.Errorf("Alarm should be restored from backup")
+	}
+}
+
+func TestFailedRestoreChangesNothing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	jobDBFile := filepath.Join(dir, "job.db")
+	userStateDBFile := filepath.Join(dir, "user_state.db")
+	preferencesDBFile := filepath.Join(dir, "preferences.db")
+
+	backupStore, err := OpenStore(jobDBFile, userStateDBFile, preferencesDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath, err := backupStore.Backup(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The alarm is newer than the backup, and the backup of the last database is lost
+	backupStore.Jobs.StoreJob(BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}})
+	backupStore.Close()
+	os.Remove(filepath.Join(backupPath, "preferences.db"))
+
+	if err := restoreBackup(backupPath, jobDBFile, userStateDBFile, preferencesDBFile); err == nil {
+		t.Fatal("Expected the restore to fail without the backup of preferences.db")
+	}
+
+	restoreFiles, _ := filepath.Glob(filepath.Join(dir, "*.restore"))
+	if len(restoreFiles) != 0 {
+		t.Errorf("Expected the copies of the backup to be removed but got %v", restoreFiles)
+	}
+	store, err := OpenStore(jobDBFile, userStateDBFile, preferencesDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if jobs, _ := store.Jobs.GetJobsByChatID(12345); len(jobs) != 1 {
+		t.Errorf("Expected job.db not to be restored when another database cannot be")
+	}
+}
+
+func TestPruneBackupsKeepsNewest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"20200101-030000", "20200102-030000", "20200103-030000", "not-a-backup"} {
+		os.Mkdir(filepath.Join(dir, name), 0700)
+	}
+
+	if err := pruneBackups(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := listBackups(dir)
+	if len(backups) != 2 || filepath.Base(backups[0]) != "20200102-030000" || filepath.Base(backups[1]) != "20200103-030000" {
+		t.Errorf("Only the 2 newest backups should be kept, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "not-a-backup")); err != nil {
+		t.Errorf("Directories that are not backups should be left alone")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+)
+
+// botStats counts what has happened today, for admins to see with /stats. The counts start again every day
+type botStats struct {
+	mutex          sync.Mutex
+	day            string
+	alarmsFired    int
+	datamallErrors int
+	telegramErrors int
+}
+
+// botStatsSnapshot is a copy of the counts of botStats
+type botStatsSnapshot struct {
+	alarmsFired    int
+	datamallErrors int
+	telegramErrors int
+}
+
+var todayStats = &botStats{}
+
+// increment adds one to the counter, after starting the counts again if the day has changed
+func (s *botStats) increment(counter func(*botStats) *int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resetIfNewDay()
+	*counter(s)++
+}
+
+func (s *botStats) resetIfNewDay() {
+	today := now().Format("2006-01-02")
+	if s.day != today {
+		s.day = today
+		s.alarmsFired = 0
+		s.datamallErrors = 0
+		s.telegramErrors = 0
+	}
+}
+
+// AlarmFired counts a scheduled bus alarm that went off
+func (s *botStats) AlarmFired() {
+	s.increment(func(s *botStats) *int { return &s.alarmsFired })
+}
+
+// DatamallError counts a failed request to LTA DataMall
+func (s *botStats) DatamallError() {
+	s.increment(func(s *botStats) *int { return &s.datamallErrors })
+}
+
+// TelegramError counts a failed request to the Telegram bot API
+func (s *botStats) TelegramError() {
+	s.increment(func(s *botStats) *int { return &s.telegramErrors })
+}
+
+// Snapshot returns today's counts
+func (s *botStats) Snapshot() botStatsSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resetIfNewDay()
+	return botStatsSnapshot{alarmsFired: s.alarmsFired, datamallErrors: s.datamallErrors, telegramErrors: s.telegramErrors}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -22,53 +20,170 @@ func getMinutesFromNow(arrivingBus datamall.ArrivingBus) float64 {
 	return delta
 }
 
-// NextBusMinutes, NextBusMinutes2, NextBusMinutes3 will contain negative value
-// if arrival information is not available
+// Bus load, feature and type values as given by the LTA API
+const (
+	loadSeatsAvailable    string = "SEA"
+	loadStandingAvailable string = "SDA"
+	loadLimitedStanding   string = "LSD"
+	featureWheelchair     string = "WAB"
+	typeDoubleDeck        string = "DD"
+	typeBendy             string = "BD"
+)
+
+// arrivingBusInformation contains information about a single incoming bus,
+// Minutes will contain negative value if arrival information is not available
+type arrivingBusInformation struct {
+	Minutes          float64
+	EstimatedArrival time.Time
+	Load             string
+	Feature          string
+	Type             string
+}
+
+func newArrivingBusInformation(arrivingBus datamall.ArrivingBus) arrivingBusInformation {
+	return arrivingBusInformation{
+		Minutes:          getMinutesFromNow(arrivingBus),
+		EstimatedArrival: arrivingBus.EstimatedArrival,
+		Load:             arrivingBus.Load,
+		Feature:          arrivingBus.Feature,
+		Type:             arrivingBus.Type,
+	}
+}
+
+// toString returns the arrival time followed by the crowd level, wheelchair accessibility and bus type
+// e.g. "5 mins 🟡 ♿ double-decker" or "08:03 🟡 ♿ double-decker"
+func (arrivingBus arrivingBusInformation) toString(preferences Preferences) string {
+	language := languageOf(preferences)
+	// Without an estimate, there is no arrival time to show, nor a bus to describe
+	if arrivingBus.Minutes < 0 {
+		return translate(language, "arrival.unknown")
+	}
+	stringBuilder := strings.Builder{}
+	if arrivingBus.Minutes == 0 {
+		stringBuilder.WriteString(translate(language, "arrival.arriving"))
+	} else if preferences.ClockTime {
+		stringBuilder.WriteString(arrivingBus.EstimatedArrival.In(location).Format("15:04"))
+	} else {
+		stringBuilder.WriteString(translate(language, "arrival.minutes", arrivingBus.Minutes))
+	}
+
+	switch arrivingBus.Load {
+	case loadSeatsAvailable:
+		stringBuilder.WriteString(" 🟢")
+	case loadStandingAvailable:
+		stringBuilder.WriteString(" 🟡")
+	case loadLimitedStanding:
+		stringBuilder.WriteString(" 🔴")
+	}
+	if arrivingBus.Feature == featureWheelchair {
+		stringBuilder.WriteString(" ♿")
+	}
+	switch arrivingBus.Type {
+	case typeDoubleDeck:
+		stringBuilder.WriteString(" " + translate(language, "arrival.doubleDecker"))
+	case typeBendy:
+		stringBuilder.WriteString(" " + translate(language, "arrival.bendy"))
+	}
+	return stringBuilder.String()
+}
+
 type busArrivalInformation struct {
-	BusStopCode     string
-	BusServiceNo    string
-	NextBusMinutes  float64
-	NextBusMinutes2 float64
-	NextBusMinutes3 float64
+	BusStopCode  string
+	BusServiceNo string
+	NextBus      arrivingBusInformation
+	NextBus2     arrivingBusInformation
+	NextBus3     arrivingBusInformation
 }
 
-func (busArrivalInformation busArrivalInformation) toMessageString() string {
+// toMessageString renders the arrival information in the format that the user prefers
+// Compact: "506 @ Desc (43411) | Arr | 5 mins | 12 mins"
+// Verbose: one line for the bus stop, followed by one line for each incoming bus
+func (busArrivalInformation busArrivalInformation) toMessageString(preferences Preferences) string {
+	language := languageOf(preferences)
+	busStop := busArrivalInformation.BusStopCode
+	if !preferences.HideBusStopDescription {
+		busStopDesc := refDataDB.GetBusStopByBusStopCode(busArrivalInformation.BusStopCode).Description
+		busStop = fmt.Sprintf("%s (%s)", busStopDesc, busArrivalInformation.BusStopCode)
+	}
+
 	stringBuilder := strings.Builder{}
+	if preferences.Verbose {
+		stringBuilder.WriteString(translate(language, "arrival.title", busArrivalInformation.BusServiceNo, busStop))
+		stringBuilder.WriteString("\n")
+		stringBuilder.WriteString(translate(language, "arrival.nextBus", busArrivalInformation.NextBus.toString(preferences)))
+		if busArrivalInformation.NextBus2.Minutes > 0 {
+			stringBuilder.WriteString("\n")
+			stringBuilder.WriteString(translate(language, "arrival.secondBus", busArrivalInformation.NextBus2.toString(preferences)))
+		}
+		if busArrivalInformation.NextBus3.Minutes > 0 {
+			stringBuilder.WriteString("\n")
+			stringBuilder.WriteString(translate(language, "arrival.thirdBus", busArrivalInformation.NextBus3.toString(preferences)))
+		}
+		return stringBuilder.String()
+	}
+
 	stringBuilder.WriteString(busArrivalInformation.BusServiceNo)
 	stringBuilder.WriteString(" @ ")
-	busStopDesc := refDataDB.GetBusStopByBusStopCode(busArrivalInformation.BusStopCode).Description
-	stringBuilder.WriteString(fmt.Sprintf("%s (%s)", busStopDesc, busArrivalInformation.BusStopCode))
+	stringBuilder.WriteString(busStop)
 	stringBuilder.WriteString(" | ")
-	if busArrivalInformation.NextBusMinutes == 0 {
-		stringBuilder.WriteString("Arr")
-	} else {
-		stringBuilder.WriteString(fmt.Sprintf("%.0f mins", busArrivalInformation.NextBusMinutes))
-	}
-	if busArrivalInformation.NextBusMinutes2 > 0 {
+	stringBuilder.WriteString(busArrivalInformation.NextBus.toString(preferences))
+	if busArrivalInformation.NextBus2.Minutes > 0 {
 		stringBuilder.WriteString(" | ")
-		stringBuilder.WriteString(fmt.Sprintf("%.0f mins", busArrivalInformation.NextBusMinutes2))
+		stringBuilder.WriteString(busArrivalInformation.NextBus2.toString(preferences))
 	}
-	if busArrivalInformation.NextBusMinutes3 > 0 {
+	if busArrivalInformation.NextBus3.Minutes > 0 {
 		stringBuilder.WriteString(" | ")
-		stringBuilder.WriteString(fmt.Sprintf("%.0f mins", busArrivalInformation.NextBusMinutes3))
+		stringBuilder.WriteString(busArrivalInformation.NextBus3.toString(preferences))
 	}
 	return stringBuilder.String()
 }
 
-func fetchBusArrivalInformation(busStopCode string, busServiceNo string) busArrivalInformation {
-	ltaToken := os.Getenv("LTA_API_TOKEN")
-	apiClient := datamall.NewDefaultClient(ltaToken)
-	resPayload, err := apiClient.GetBusArrival(busStopCode, busServiceNo)
+// fetchBusArrivalInformation retrieves the arrival information of a bus service at the bus stop
+func fetchBusArrivalInformation(busStopCode string, busServiceNo string) (busArrivalInformation, error) {
+	apiClient := datamall.NewDefaultClient(config.LTAAPIToken)
+	var resPayload datamall.BusArrival
+	err := observeDatamallRequest("bus_arrival", func() (err error) {
+		resPayload, err = apiClient.GetBusArrival(busStopCode, busServiceNo)
+		return err
+	})
+	if err != nil {
+		todayStats.DatamallError()
+		return busArrivalInformation{}, err
+	}
+	if len(resPayload.Services) == 0 {
+		return busArrivalInformation{}, fmt.Errorf("Bus %s is not in service at %s", busServiceNo, busStopCode)
+	}
+
+	return newBusArrivalInformation(resPayload.BusStopCode, resPayload.Services[0]), nil
+}
+
+// fetchBusArrivalsAtBusStop retrieves the arrival information of every bus service at the bus stop
+func fetchBusArrivalsAtBusStop(busStopCode string) ([]busArrivalInformation, error) {
+	apiClient := datamall.NewDefaultClient(config.LTAAPIToken)
+	var resPayload datamall.BusArrival
+	err := observeDatamallRequest("bus_arrival", func() (err error) {
+		resPayload, err = apiClient.GetBusArrival(busStopCode, "")
+		return err
+	})
 	if err != nil {
-		log.Fatalln(err)
+		todayStats.DatamallError()
+		return nil, err
+	}
+
+	busArrivals := []busArrivalInformation{}
+	for _, service := range resPayload.Services {
+		busArrivals = append(busArrivals, newBusArrivalInformation(resPayload.BusStopCode, service))
 	}
+	return busArrivals, nil
+}
 
+func newBusArrivalInformation(busStopCode string, service datamall.Service) busArrivalInformation {
 	busArrivalInfo := busArrivalInformation{}
-	busArrivalInfo.BusStopCode = resPayload.BusStopCode
-	busArrivalInfo.BusServiceNo = resPayload.Services[0].ServiceNo
-	busArrivalInfo.NextBusMinutes = getMinutesFromNow(resPayload.Services[0].NextBus)
-	busArrivalInfo.NextBusMinutes2 = getMinutesFromNow(resPayload.Services[0].NextBus2)
-	busArrivalInfo.NextBusMinutes3 = getMinutesFromNow(resPayload.Services[0].NextBus3)
+	busArrivalInfo.BusStopCode = busStopCode
+	busArrivalInfo.BusServiceNo = service.ServiceNo
+	busArrivalInfo.NextBus = newArrivingBusInformation(service.NextBus)
+	busArrivalInfo.NextBus2 = newArrivingBusInformation(service.NextBus2)
+	busArrivalInfo.NextBus3 = newArrivingBusInformation(service.NextBus3)
 
 	return busArrivalInfo
 }
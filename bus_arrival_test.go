@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/yi-jiayu/datamall/v3"
 )
@@ -13,3 +14,52 @@ func TestNoArrivalInformationReturnsNegativeValue(t *testing.T) {
 		t.Errorf("Minutes should be negative but it's not")
 	}
 }
+
+func TestArrivingBusStringShowsLoadFeatureAndType(t *testing.T) {
+	arrivingBus := arrivingBusInformation{Minutes: 5, Load: "SDA", Feature: "WAB", Type: "DD"}
+	if arrivingBus.toString(Preferences{}) != "5 mins 🟡 ♿ double-decker" {
+		t.Errorf("Unexpected arriving bus string: %s", arrivingBus.toString(Preferences{}))
+	}
+
+	arrivingBus = arrivingBusInformation{Minutes: 0, Load: "SEA", Type: "SD"}
+	if arrivingBus.toString(Preferences{}) != "Arr 🟢" {
+		t.Errorf("Unexpected arriving bus string: %s", arrivingBus.toString(Preferences{}))
+	}
+}
+
+func TestArrivingBusStringShowsClockTime(t *testing.T) {
+	estimatedArrival := time.Now().Add(10 * time.Minute)
+	arrivingBus := arrivingBusInformation{Minutes: 10, EstimatedArrival: estimatedArrival}
+	if arrivingBus.toString(Preferences{ClockTime: true}) != estimatedArrival.Format("15:04") {
+		t.Errorf("Unexpected arriving bus string: %s", arrivingBus.toString(Preferences{ClockTime: true}))
+	}
+}
+
+func TestArrivingBusStringWithoutEstimate(t *testing.T) {
+	arrivingBus := arrivingBusInformation{Minutes: -1, Load: "SEA", Type: "DD"}
+	for _, preferences := range []Preferences{{}, {ClockTime: true}} {
+		if arrivingBus.toString(preferences) != "No estimate" {
+			t.Errorf("Unexpected arriving bus string: %s", arrivingBus.toString(preferences))
+		}
+	}
+}
+
+func TestBusArrivalMessageWithoutBusStopDescription(t *testing.T) {
+	busArrivalInfo := busArrivalInformation{
+		BusStopCode:  "43411",
+		BusServiceNo: "506",
+		NextBus:      arrivingBusInformation{Minutes: 0},
+		NextBus2:     arrivingBusInformation{Minutes: 5},
+		NextBus3:     arrivingBusInformation{Minutes: -1},
+	}
+
+	compact := busArrivalInfo.toMessageString(Preferences{HideBusStopDescription: true})
+	if compact != "506 @ 43411 | Arr | 5 mins" {
+		t.Errorf("Unexpected compact message: %s", compact)
+	}
+
+	verbose := busArrivalInfo.toMessageString(Preferences{Verbose: true, HideBusStopDescription: true})
+	if verbose != "Bus 506 @ 43411\nNext bus: Arr\n2nd bus: 5 mins" {
+		t.Errorf("Unexpected verbose message: %s", verbose)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bus-notifier/refdata"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// command is a subcommand of bus-notifier, such as "jobs export".
+// run registers the command's own flags on flagSet, then loads the config with them
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(flagSet *flag.FlagSet, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Run the bot (default when no command is given)", serve},
+		{"refdata download", "", "Download the reference data from LTA DataMall", runRefDataDownload},
+		{"refdata inspect", "", "Show a summary of the reference data, or a bus service's route or a bus stop", runRefDataInspect},
+		{"refdata verify", "", "Check that the bus services file and the reference data db agree", runRefDataVerify},
+		{"jobs list", "", "List the registered bus alarms", runJobsList},
+		{"jobs export", "", "Export the registered bus alarms as JSON", runJobsExport},
+		{"jobs import", "<file>", "Register the bus alarms in a file written by jobs export", runJobsImport},
+		{"db migrate", "", "Migrate the databases to the latest schema version", runDBMigrate},
+		{"db backup", "", "Back up the databases into the backup directory", runDBBackup},
+		{"db fsck", "", "Check the job database for inconsistencies", runDBFsck},
+		{"db restore", "<backup directory>", "Restore the databases from a backup", runDBRestore},
+	}
+}
+
+// findCommand returns the command named by the start of args, and the rest of args.
+// Without a command, or when args start with a flag, the bot is served
+func findCommand(args []string) (*command, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return &commands[0], args
+	}
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: bus-notifier <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tabWriter := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tabWriter, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.description)
+	}
+	tabWriter.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run bus-notifier <command> -h for the flags of a command")
+}
+
+// newCommandFlagSet returns the flag set for the command, which prints the command's usage on -h
+func newCommandFlagSet(cmd *command) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("bus-notifier "+cmd.name, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: bus-notifier %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.description)
+		flagSet.PrintDefaults()
+	}
+	return flagSet
+}
+
+// loadConfig loads the config for the command, with the command's flags already registered on flagSet
+func loadConfig(flagSet *flag.FlagSet, args []string) error {
+	var err error
+	config, err = LoadConfig(flagSet, args)
+	if err != nil {
+		return err
+	}
+	location = config.Location
+	configureLogging(config.LogLevel, config.LogFormat, config.TelegramAPIToken, config.LTAAPIToken, config.WebhookSecretToken)
+	return nil
+}
+
+// openCommandStore opens the databases for a command, failing quickly if the bot is running and holds their locks
+func openCommandStore(migrate bool) (*Store, error) {
+	open := OpenStoreWithoutMigrating
+	if migrate {
+		open = OpenStore
+	}
+	commandStore, err := open(config.JobDBFile, config.UserStateDBFile, config.PreferencesDBFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open the databases, stop the bot first if it is running: %v", err)
+	}
+	return commandStore, nil
+}
+
+// openExistingRefDataDB opens the reference data db, without creating an empty one if it has not been downloaded
+func openExistingRefDataDB() (refdata.DB, error) {
+	if _, err := os.Stat(config.RefDataDBFile); err != nil {
+		return refdata.DB{}, fmt.Errorf("Unable to find the reference data db, run refdata download first: %v", err)
+	}
+	return refdata.OpenRefDataDB(config.RefDataDBFile)
+}
+
+func runRefDataDownload(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+	if config.LTAAPIToken == "" {
+		return errors.New("LTA_API_TOKEN must be set to download the reference data")
+	}
+
+	busRoutes, busStops, busServiceNos, err := refdata.Download(config.LTAAPIToken)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Storing data into reference data db...")
+	downloadedRefDataDB, err := refdata.OpenRefDataDB(config.RefDataDBFile)
+	if err != nil {
+		return fmt.Errorf("Unable to open %s, stop the bot first if it is running: %v", config.RefDataDBFile, err)
+	}
+	defer downloadedRefDataDB.Close()
+	if err := downloadedRefDataDB.StoreBusRoutes(busRoutes); err != nil {
+		return err
+	}
+	if err := downloadedRefDataDB.StoreBusStops(busStops); err != nil {
+		return err
+	}
+
+	// Written beside the file and renamed over it, so that the bot never reads a partial list
+	tempFile := config.BusServicesFile + ".download"
+	if err := ioutil.WriteFile(tempFile, []byte(strings.Join(busServiceNos, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile, config.BusServicesFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reference data downloaded and stored! %d bus services, %d bus stops\n", len(busServiceNos), len(busStops))
+	return nil
+}
+
+func runRefDataInspect(flagSet *flag.FlagSet, args []string) error {
+	busServiceNo := flagSet.String("service", "", "show the route of this bus service")
+	busStopCode := flagSet.String("stop", "", "show this bus stop")
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	inspectedRefDataDB, err := openExistingRefDataDB()
+	if err != nil {
+		return err
+	}
+	defer inspectedRefDataDB.Close()
+
+	switch {
+	case *busServiceNo != "":
+		busRoutes := inspectedRefDataDB.GetBusRoutesByBusService(*busServiceNo)
+		if len(busRoutes) == 0 {
+			return fmt.Errorf("Bus service %s has no route", *busServiceNo)
+		}
+		sort.Slice(busRoutes, func(i, j int) bool {
+			if busRoutes[i].Direction != busRoutes[j].Direction {
+				return busRoutes[i].Direction < busRoutes[j].Direction
+			}
+			return busRoutes[i].StopSequence < busRoutes[j].StopSequence
+		})
+		tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "DIRECTION\tSEQUENCE\tSTOP\tDESCRIPTION")
+		for _, busRoute := range busRoutes {
+			fmt.Fprintf(tabWriter, "%d\t%d\t%s\t%s\n", busRoute.Direction, busRoute.StopSequence, busRoute.BusStopCode, busRoute.Description)
+		}
+		return tabWriter.Flush()
+	case *busStopCode != "":
+		busStop := inspectedRefDataDB.GetBusStopByBusStopCode(*busStopCode)
+		if busStop.BusStopCode == "" {
+			return fmt.Errorf("Bus stop %s does not exist", *busStopCode)
+		}
+		fmt.Println(busStop.BusStopCode, busStop.Description)
+		return nil
+	}
+
+	busServiceNos, err := inspectedRefDataDB.GetBusServiceNos()
+	if err != nil {
+		return err
+	}
+	busStopCount, err := inspectedRefDataDB.CountBusStops()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Reference data db:", config.RefDataDBFile)
+	fmt.Println("Bus services with a route:", len(busServiceNos))
+	fmt.Println("Bus stops:", busStopCount)
+	return nil
+}
+
+func runRefDataVerify(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	verifiedRefDataDB, err := openExistingRefDataDB()
+	if err != nil {
+		return err
+	}
+	defer verifiedRefDataDB.Close()
+
+	problems, err := verifyRefData(config.BusServicesFile, verifiedRefDataDB)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("Found %d problems in the reference data, run refdata download to fix them", len(problems))
+	}
+	fmt.Println("Reference data is consistent")
+	return nil
+}
+
+// verifyRefData reports bus services that are only in one of the bus services file and the reference data db,
+// and bus stops on a route that are missing from the reference data db
+func verifyRefData(busServicesFile string, verifiedRefDataDB refdata.DB) ([]string, error) {
+	listedBusServices, err := readBusServices(busServicesFile)
+	if err != nil {
+		return nil, err
+	}
+	busServiceNos, err := verifiedRefDataDB.GetBusServiceNos()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	if len(listedBusServices) == 0 {
+		problems = append(problems, fmt.Sprintf("%s lists no bus services", busServicesFile))
+	}
+	routedBusServices := make(map[string]bool)
+	missingBusStops := make(map[string]bool)
+	for _, busServiceNo := range busServiceNos {
+		routedBusServices[busServiceNo] = true
+		if !listedBusServices[busServiceNo] {
+			problems = append(problems, fmt.Sprintf("Bus service %s has a route but is not listed in %s", busServiceNo, busServicesFile))
+		}
+		for _, busRoute := range verifiedRefDataDB.GetBusRoutesByBusService(busServiceNo) {
+			if missingBusStops[busRoute.BusStopCode] {
+				continue
+			}
+			if verifiedRefDataDB.GetBusStopByBusStopCode(busRoute.BusStopCode).BusStopCode == "" {
+				missingBusStops[busRoute.BusStopCode] = true
+				problems = append(problems, fmt.Sprintf("Bus stop %s on the route of bus service %s does not exist", busRoute.BusStopCode, busServiceNo))
+			}
+		}
+	}
+
+	unroutedBusServices := []string{}
+	for busServiceNo := range listedBusServices {
+		if !routedBusServices[busServiceNo] {
+			unroutedBusServices = append(unroutedBusServices, busServiceNo)
+		}
+	}
+	sort.Strings(unroutedBusServices)
+	for _, busServiceNo := range unroutedBusServices {
+		problems = append(problems, fmt.Sprintf("Bus service %s is listed in %s but has no route", busServiceNo, busServicesFile))
+	}
+	return problems, nil
+}
+
+func runJobsList(flagSet *flag.FlagSet, args []string) error {
+	chatID := flagSet.Int64("chat", 0, "only list the bus alarms of this chat ID")
+	day := flagSet.String("day", "", "only list the bus alarms on this day, such as Monday")
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+	var weekday time.Weekday
+	if *day != "" {
+		var err error
+		if weekday, err = parseWeekday(*day); err != nil {
+			return err
+		}
+	}
+
+	listStore, err := openCommandStore(true)
+	if err != nil {
+		return err
+	}
+	defer listStore.Close()
+
+	jobs, err := listStore.Jobs.GetAllJobs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "ID\tCHAT\tTIME\tBUS\tSTOP\tDAYS")
+	for _, job := range jobs {
+		if *chatID != 0 && job.ChatID != *chatID {
+			continue
+		}
+		if *day != "" && !job.HasWeekday(weekday) {
+			continue
+		}
+		fmt.Fprintf(tabWriter, "%d\t%d\t%s\t%s\t%s\t%s\n", job.ID, job.ChatID, job.ScheduledTime.ToString(), job.BusServiceNo, job.BusStopCode, joinDaysString(defaultLanguage, job.Weekdays))
+	}
+	return tabWriter.Flush()
+}
+
+func runJobsExport(flagSet *flag.FlagSet, args []string) error {
+	output := flagSet.String("o", "", "write to this file instead of standard output")
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	exportStore, err := openCommandStore(true)
+	if err != nil {
+		return err
+	}
+	defer exportStore.Close()
+
+	// Alarms that cannot be decoded cannot be exported either, so they are left out rather than failing the export
+	jobs, err := exportStore.Jobs.GetAllJobs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if *output == "" {
+		return exportJobs(os.Stdout, jobs)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := exportJobs(file, jobs); err != nil {
+		file.Close()
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d bus alarms to %s\n", len(jobs), *output)
+	return file.Close()
+}
+
+func runJobsImport(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	jobs, err := importJobs(file)
+	if err != nil {
+		return err
+	}
+
+	importStore, err := openCommandStore(true)
+	if err != nil {
+		return err
+	}
+	defer importStore.Close()
+
+	// Imported alarms are given new IDs, so that they never overwrite existing alarms.
+	// Their days are added to the same alarms that are already registered instead
+	duplicates := 0
+	for i, job := range jobs {
+		_, coveredDays, err := storeOrMergeJob(importStore.Jobs, job)
+		if err != nil {
+			return fmt.Errorf("Imported %d of %d bus alarms before failing: %v", i, len(jobs), err)
+		}
+		if len(coveredDays) == len(job.Weekdays) {
+			duplicates++
+		}
+	}
+	fmt.Printf("Imported %d bus alarms\n", len(jobs)-duplicates)
+	if duplicates > 0 {
+		fmt.Printf("Skipped %d bus alarms that were already registered\n", duplicates)
+	}
+	return nil
+}
+
+func runDBMigrate(flagSet *flag.FlagSet, args []string) error {
+	dryRun := flagSet.Bool("dry-run", false, "report the pending migrations without making them")
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	migrateStore, err := openCommandStore(false)
+	if err != nil {
+		return err
+	}
+	defer migrateStore.Close()
+
+	reports, err := migrateStore.Migrate(*dryRun)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("All databases are at the latest schema version")
+	}
+	for _, report := range reports {
+		fmt.Printf("%s: migration %d (%s)\n", report.dbFile, report.version, report.description)
+		for _, change := range report.changes {
+			fmt.Println("  ", change)
+		}
+	}
+	return nil
+}
+
+func runDBBackup(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	backupStore, err := openCommandStore(false)
+	if err != nil {
+		return err
+	}
+	defer backupStore.Close()
+
+	backupPath, err := backupStore.Backup(config.BackupDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Backed up to", backupPath)
+	return pruneBackups(config.BackupDir, config.BackupRetain)
+}
+
+func runDBFsck(flagSet *flag.FlagSet, args []string) error {
+	repair := flagSet.Bool("repair", false, "repair the inconsistencies that are found")
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+
+	fsckStore, err := openCommandStore(false)
+	if err != nil {
+		return err
+	}
+	defer fsckStore.Close()
+
+	inconsistencies, err := fsckStore.CheckJobConsistency(*repair)
+	if err != nil {
+		return err
+	}
+	if len(inconsistencies) == 0 {
+		fmt.Println("No inconsistencies found in", config.JobDBFile)
+	}
+	for _, inconsistency := range inconsistencies {
+		fmt.Println(inconsistency)
+	}
+	return nil
+}
+
+func runDBRestore(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	return restoreBackup(filepath.Clean(flagSet.Arg(0)), config.JobDBFile, config.UserStateDBFile, config.PreferencesDBFile)
+}
+
+// parseWeekday returns the weekday with the given English name, such as Monday
+func parseWeekday(name string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a day of the week", name)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bus-notifier/refdata"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	cmd, args := findCommand([]string{"jobs", "import", "alarms.json"})
+	if cmd == nil || cmd.name != "jobs import" || len(args) != 1 || args[0] != "alarms.json" {
+		t.Errorf("Unexpected command %v with args %v", cmd, args)
+	}
+
+	// Flags without a command are passed to serve, as before there were commands
+	cmd, args = findCommand([]string{"-debug", "true"})
+	if cmd == nil || cmd.name != "serve" || len(args) != 2 {
+		t.Errorf("Unexpected command %v with args %v", cmd, args)
+	}
+
+	if cmd, _ := findCommand([]string{"jobs", "delete"}); cmd != nil {
+		t.Errorf("Expected no command but got %s", cmd.name)
+	}
+}
+
+func TestVerifyRefData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	busServicesFile := filepath.Join(dir, "bus_services.txt")
+	if err := ioutil.WriteFile(busServicesFile, []byte("506\n963\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testRefDataDB, err := refdata.OpenRefDataDB(filepath.Join(dir, "refdata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testRefDataDB.Close()
+	testRefDataDB.StoreBusStops([]refdata.BusStop{{BusStopCode: "43411", Description: "Opp Blk 123"}})
+	testRefDataDB.StoreBusRoutes([]refdata.BusRoute{
+		{BusServiceNo: "506", BusStop: refdata.BusStop{BusStopCode: "43411"}, Direction: 1, StopSequence: 1},
+		{BusServiceNo: "506", BusStop: refdata.BusStop{BusStopCode: "43419"}, Direction: 1, StopSequence: 2},
+		{BusServiceNo: "61", BusStop: refdata.BusStop{BusStopCode: "43411"}, Direction: 1, StopSequence: 1},
+	})
+
+	problems, err := verifyRefData(busServicesFile, testRefDataDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Bus stop 43419", "Bus service 61 has a route but is not listed", "Bus service 963 is listed"}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems but got %v", len(expected), problems)
+	}
+	for _, prefix := range expected {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, prefix)
+		}
+		if !found {
+			t.Errorf("Expected a problem starting with %q in %v", prefix, problems)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
+)
+
+// Config contains everything that can be configured about the bot
+type Config struct {
+	TelegramAPIToken string
+	LTAAPIToken      string
+	LogLevel         logLevel
+	LogFormat        string
+	Location         *time.Location
+	AdminChatIDs     []int64
+
+	JobDBFile         string
+	UserStateDBFile   string
+	PreferencesDBFile string
+	BusServicesFile   string
+	RefDataDBFile     string
+	StorageBackend    string
+
+	UpdateMode           string
+	WebhookListenAddress string
+	WebhookURL           string
+	WebhookSecretToken   string
+
+	MonitoringListenAddress string
+
+	BackupDir      string
+	BackupSchedule string
+	BackupRetain   int
+}
+
+// configSetting is a setting that can be given in the config file or as an environment variable by its key,
+// or as a command line flag by its flag name. Flags take precedence over environment variables,
+// which take precedence over the config file
+type configSetting struct {
+	key          string
+	flagName     string
+	defaultValue string
+	usage        string
+}
+
+var configSettings = []configSetting{
+	{"TELEGRAM_API_TOKEN", "telegram-api-token", "", "Telegram bot API token"},
+	{"LTA_API_TOKEN", "lta-api-token", "", "LTA DataMall API account key"},
+	{"LOG_LEVEL", "log-level", "info", "debug, info, warn or error. Users' messages and Telegram API payloads are only logged at debug"},
+	{"LOG_FORMAT", "log-format", "logfmt", "logfmt or json"},
+	{"TIMEZONE", "timezone", "Asia/Singapore", "timezone that alarms are scheduled in"},
+	{"ADMIN_CHAT_IDS", "admin-chat-ids", "", "comma separated chat IDs that are allowed to use admin commands"},
+	{"JOB_DB_FILE", "job-db", "job.db", "path of the job database"},
+	{"USER_STATE_DB_FILE", "user-state-db", "user_state.db", "path of the user state database"},
+	{"PREFERENCES_DB_FILE", "preferences-db", "preferences.db", "path of the preferences database"},
+	{"BUS_SERVICES_FILE", "bus-services", "refdata/bus_services.txt", "path of the list of bus services"},
+	{"REFDATA_DB_FILE", "refdata-db", "refdata/refdata.db", "path of the reference data database"},
+	{"STORAGE_BACKEND", "storage-backend", "bolt", "bolt, or memory for local development"},
+	{"TELEGRAM_UPDATE_MODE", "update-mode", "polling", "polling, or webhook to receive updates by webhook"},
+	{"WEBHOOK_LISTEN_ADDRESS", "webhook-listen-address", ":8443", "address that the webhook listens on"},
+	{"WEBHOOK_URL", "webhook-url", "", "public URL of the webhook"},
+	{"WEBHOOK_SECRET_TOKEN", "webhook-secret-token", "", "secret token that Telegram sends with every webhook request"},
+	{"MONITORING_LISTEN_ADDRESS", "monitoring-listen-address", ":2112", "address that /metrics, /healthz and /readyz are served on, empty to turn them off"},
+	{"BACKUP_DIR", "backup-dir", "backups", "directory that backups are kept in"},
+	{"BACKUP_SCHEDULE", "backup-schedule", "0 3 * * *", "cron expression of when backups are taken"},
+	{"BACKUP_RETAIN", "backup-retain", "7", "number of backups to keep"},
+}
+
+var config *Config
+
+// location is the timezone that alarms are scheduled and bus arrivals are shown in, set from the config at startup
+var location = time.Local
+
+// now returns the current time in the configured timezone
+func now() time.Time {
+	return time.Now().In(location)
+}
+
+// defaultConfigFile is read if it exists, when no config file is given
+const defaultConfigFile string = ".env"
+
+// LoadConfig registers the config flags on flagSet and parses args, then reads the config file and environment variables.
+// The config file contains KEY=VALUE lines, in the same format as a .env file
+func LoadConfig(flagSet *flag.FlagSet, args []string) (*Config, error) {
+	configFile := flagSet.String("config", "", "path of the config file (default "+defaultConfigFile+" if it exists)")
+	flagValues := make(map[string]*string)
+	for _, setting := range configSettings {
+		flagValues[setting.flagName] = flagSet.String(setting.flagName, "", fmt.Sprintf("%s (%s, default %q)", setting.usage, setting.key, setting.defaultValue))
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, setting := range configSettings {
+		values[setting.key] = setting.defaultValue
+	}
+
+	// A missing config file is only an error if it was asked for
+	fileName := defaultConfigFile
+	if *configFile != "" {
+		fileName = *configFile
+	}
+	fileValues, err := godotenv.Read(fileName)
+	if err != nil && (*configFile != "" || !os.IsNotExist(err)) {
+		return nil, fmt.Errorf("Unable to read config file %s: %v", fileName, err)
+	}
+	for key, value := range fileValues {
+		values[key] = value
+	}
+
+	for _, setting := range configSettings {
+		if value, ok := os.LookupEnv(setting.key); ok {
+			values[setting.key] = value
+		}
+	}
+
+	flagSet.Visit(func(f *flag.Flag) {
+		for _, setting := range configSettings {
+			if setting.flagName == f.Name {
+				values[setting.key] = *flagValues[f.Name]
+			}
+		}
+	})
+
+	return parseConfig(values)
+}
+
+// parseConfig converts the settings into a Config, reporting every invalid setting at once
+func parseConfig(values map[string]string) (*Config, error) {
+	problems := []string{}
+	config := &Config{
+		TelegramAPIToken:        values["TELEGRAM_API_TOKEN"],
+		LTAAPIToken:             values["LTA_API_TOKEN"],
+		JobDBFile:               values["JOB_DB_FILE"],
+		UserStateDBFile:         values["USER_STATE_DB_FILE"],
+		PreferencesDBFile:       values["PREFERENCES_DB_FILE"],
+		BusServicesFile:         values["BUS_SERVICES_FILE"],
+		RefDataDBFile:           values["REFDATA_DB_FILE"],
+		StorageBackend:          values["STORAGE_BACKEND"],
+		UpdateMode:              values["TELEGRAM_UPDATE_MODE"],
+		WebhookListenAddress:    values["WEBHOOK_LISTEN_ADDRESS"],
+		WebhookURL:              values["WEBHOOK_URL"],
+		WebhookSecretToken:      values["WEBHOOK_SECRET_TOKEN"],
+		MonitoringListenAddress: values["MONITORING_LISTEN_ADDRESS"],
+		BackupDir:               values["BACKUP_DIR"],
+		BackupSchedule:          values["BACKUP_SCHEDULE"],
+	}
+
+	var err error
+	if config.LogLevel, err = parseLogLevel(values["LOG_LEVEL"]); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL %v", err))
+	}
+	if config.LogFormat = values["LOG_FORMAT"]; config.LogFormat != "logfmt" && config.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be logfmt or json, not %q", config.LogFormat))
+	}
+	if config.Location, err = time.LoadLocation(values["TIMEZONE"]); err != nil {
+		problems = append(problems, fmt.Sprintf("TIMEZONE %q is not a known timezone", values["TIMEZONE"]))
+	}
+	for _, adminChatID := range strings.Split(values["ADMIN_CHAT_IDS"], ",") {
+		if strings.TrimSpace(adminChatID) == "" {
+			continue
+		}
+		chatID, err := strconv.ParseInt(strings.TrimSpace(adminChatID), 10, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("ADMIN_CHAT_IDS contains %q, which is not a chat ID", adminChatID))
+			continue
+		}
+		config.AdminChatIDs = append(config.AdminChatIDs, chatID)
+	}
+	if config.StorageBackend != "bolt" && config.StorageBackend != "memory" {
+		problems = append(problems, fmt.Sprintf("STORAGE_BACKEND must be bolt or memory, not %q", config.StorageBackend))
+	}
+	if config.UpdateMode != "polling" && config.UpdateMode != "webhook" {
+		problems = append(problems, fmt.Sprintf("TELEGRAM_UPDATE_MODE must be polling or webhook, not %q", config.UpdateMode))
+	}
+	if _, err := cron.ParseStandard(config.BackupSchedule); err != nil {
+		problems = append(problems, fmt.Sprintf("BACKUP_SCHEDULE %q is not a valid cron expression: %v", config.BackupSchedule, err))
+	}
+	if config.BackupRetain, err = strconv.Atoi(values["BACKUP_RETAIN"]); err != nil || config.BackupRetain < 1 {
+		problems = append(problems, fmt.Sprintf("BACKUP_RETAIN must be a number of at least 1, not %q", values["BACKUP_RETAIN"]))
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("Invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return config, nil
+}
+
+// ValidateForServe checks the settings that are needed to run the bot, but not the database commands
+func (c *Config) ValidateForServe() error {
+	problems := []string{}
+	if c.TelegramAPIToken == "" {
+		problems = append(problems, "TELEGRAM_API_TOKEN must be set")
+	}
+	if c.LTAAPIToken == "" {
+		problems = append(problems, "LTA_API_TOKEN must be set")
+	}
+	if c.UpdateMode == "webhook" {
+		if c.WebhookURL == "" {
+			problems = append(problems, "WEBHOOK_URL must be set in webhook mode")
+		} else if webhookURL, err := url.Parse(c.WebhookURL); err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+			problems = append(problems, fmt.Sprintf("WEBHOOK_URL %s must be an https URL", c.WebhookURL))
+		}
+		if c.WebhookSecretToken == "" {
+			problems = append(problems, "WEBHOOK_SECRET_TOKEN must be set in webhook mode")
+		}
+	}
+	if _, err := os.Stat(c.BusServicesFile); err != nil {
+		problems = append(problems, fmt.Sprintf("BUS_SERVICES_FILE %s cannot be read, generate the reference data first: %v", c.BusServicesFile, err))
+	}
+	if _, err := os.Stat(c.RefDataDBFile); err != nil {
+		problems = append(problems, fmt.Sprintf("REFDATA_DB_FILE %s cannot be read, generate the reference data first: %v", c.RefDataDBFile, err))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// IsAdmin returns true if the chat is allowed to use admin commands
+func (c *Config) IsAdmin(chatID int64) bool {
+	for _, adminChatID := range c.AdminChatIDs {
+		if adminChatID == chatID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "bot.env")
+	contents := "JOB_DB_FILE=file.db\nBACKUP_DIR=file-backups\nBACKUP_RETAIN=3\nADMIN_CHAT_IDS=1, 2\n"
+	if err := ioutil.WriteFile(configFile, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("BACKUP_DIR", "env-backups")
+	os.Setenv("BACKUP_RETAIN", "5")
+	defer os.Unsetenv("BACKUP_DIR")
+	defer os.Unsetenv("BACKUP_RETAIN")
+
+	loaded, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", configFile, "-backup-retain", "9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.UserStateDBFile != "user_state.db" {
+		t.Errorf("Expected default user state DB file but got %s", loaded.UserStateDBFile)
+	}
+	if loaded.JobDBFile != "file.db" {
+		t.Errorf("Expected job DB file from the config file but got %s", loaded.JobDBFile)
+	}
+	if loaded.BackupDir != "env-backups" {
+		t.Errorf("Expected backup dir from the environment but got %s", loaded.BackupDir)
+	}
+	if loaded.BackupRetain != 9 {
+		t.Errorf("Expected backup retain from the flag but got %d", loaded.BackupRetain)
+	}
+	if !loaded.IsAdmin(1) || !loaded.IsAdmin(2) || loaded.IsAdmin(3) {
+		t.Errorf("Unexpected admin chat IDs: %v", loaded.AdminChatIDs)
+	}
+}
+
+func TestConfigMissingFileIsOnlyAnErrorIfGiven(t *testing.T) {
+	_, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", "does-not-exist.env"})
+	if err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
+
+func TestConfigUnreadableDefaultFileIsAnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(workingDir)
+
+	if _, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), nil); err != nil {
+		t.Errorf("Expected a missing %s to be skipped but got %v", defaultConfigFile, err)
+	}
+	// A directory cannot be read as a config file
+	if err := os.Mkdir(defaultConfigFile, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), nil); err == nil {
+		t.Errorf("Expected an error for a %s that cannot be read", defaultConfigFile)
+	}
+}
+
+func TestConfigReportsEveryInvalidSetting(t *testing.T) {
+	values := map[string]string{}
+	for _, setting := range configSettings {
+		values[setting.key] = setting.defaultValue
+	}
+	values["LOG_LEVEL"] = "verbose"
+	values["LOG_FORMAT"] = "xml"
+	values["TIMEZONE"] = "Mars/Olympus_Mons"
+	values["ADMIN_CHAT_IDS"] = "1,abc"
+	values["STORAGE_BACKEND"] = "postgres"
+	values["TELEGRAM_UPDATE_MODE"] = "carrier-pigeon"
+	values["BACKUP_SCHEDULE"] = "every day"
+	values["BACKUP_RETAIN"] = "0"
+
+	_, err := parseConfig(values)
+	if err == nil {
+		t.Fatal("Expected an error for invalid settings")
+	}
+	for _, key := range []string{"LOG_LEVEL", "LOG_FORMAT", "TIMEZONE", "ADMIN_CHAT_IDS", "STORAGE_BACKEND", "TELEGRAM_UPDATE_MODE", "BACKUP_SCHEDULE", "BACKUP_RETAIN"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected %s to be reported in: %v", key, err)
+		}
+	}
+}
+
+func TestConfigValidateForServe(t *testing.T) {
+	values := map[string]string{}
+	for _, setting := range configSettings {
+		values[setting.key] = setting.defaultValue
+	}
+	values["TELEGRAM_UPDATE_MODE"] = "webhook"
+	loaded, err := parseConfig(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loaded.ValidateForServe()
+	if err == nil {
+		t.Fatal("Expected an error for missing settings")
+	}
+	for _, key := range []string{"TELEGRAM_API_TOKEN", "LTA_API_TOKEN", "WEBHOOK_URL", "WEBHOOK_SECRET_TOKEN"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected %s to be reported in: %v", key, err)
+		}
+	}
+
+	loaded.WebhookURL = "example.com/telegram/webhook"
+	if err := loaded.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "WEBHOOK_URL example.com/telegram/webhook must be an https URL") {
+		t.Errorf("Expected a webhook URL without https to be reported but got: %v", err)
+	}
+}
@@ -6,8 +6,8 @@ require (
 	github.com/boltdb/bolt v1.3.1
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/joho/godotenv v1.3.0
+	github.com/prometheus/client_golang v1.12.2
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
 	github.com/yi-jiayu/datamall/v3 v3.1.0
-	golang.org/x/sys v0.0.0-20200808120158-1030fc2bf1d9 // indirect
 )
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Commands that change a group's alarms, which only admins can send if the group is set to admins only
+var managementCommands = map[string]bool{"register": true, "delete": true, "import": true}
+
+// Commands that start or stop a registration, which only the initiator can send while their registration is in progress,
+// apart from admins stopping it with /exit
+var registrationCommands = map[string]bool{"register": true, "delete": true, "import": true, "exit": true}
+
+// isGroupChat returns true if the chat is shared by its members, whose alarms are posted to the chat
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// isGroupUpdate returns true if the update is a message or a tap on a button in a group
+func isGroupUpdate(update tgbotapi.Update) bool {
+	if update.CallbackQuery != nil {
+		return update.CallbackQuery.Message != nil && isGroupChat(update.CallbackQuery.Message.Chat)
+	}
+	return update.Message != nil && isGroupChat(update.Message.Chat)
+}
+
+// displayName returns how the user is mentioned in replies
+func displayName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return user.FirstName
+}
+
+// isMigrationUpdate returns true if the update says that the group was upgraded to a supergroup, which has a new chat ID
+func isMigrationUpdate(update tgbotapi.Update) bool {
+	return update.Message != nil && update.Message.MigrateToChatID != 0
+}
+
+// handleMigration moves the alarms, settings and registration of a group to the supergroup that it was upgraded to.
+// The old group cannot be sent messages any more, so errors are only logged
+func handleMigration(update tgbotapi.Update) registrationReply {
+	fromChatID, toChatID := update.Message.Chat.ID, update.Message.MigrateToChatID
+
+	movedJobs, err := storedJobDB.MoveJobs(fromChatID, toChatID)
+	if err != nil {
+		logError("Unable to move alarms to supergroup", "chat_id", fromChatID, "new_chat_id", toChatID, "err", err)
+		return registrationReply{}
+	}
+	rescheduleMovedJobs(cronner, fromChatID, movedJobs)
+
+	preferences, err := preferencesDB.GetPreferences(fromChatID)
+	if err == nil {
+		err = preferencesDB.SavePreferences(toChatID, preferences)
+	}
+	if err != nil {
+		logWarn("Unable to move settings to supergroup", "chat_id", fromChatID, "new_chat_id", toChatID, "err", err)
+	}
+
+	userState, err := userStateDB.GetUserState(fromChatID)
+	if err == nil && userState != nil {
+		err = userStateDB.SaveUserState(toChatID, *userState)
+	}
+	if err == nil {
+		err = userStateDB.DeleteUserState(fromChatID)
+	}
+	if err != nil {
+		logWarn("Unable to move registration to supergroup", "chat_id", fromChatID, "new_chat_id", toChatID, "err", err)
+	}
+
+	logInfo("Moved group to supergroup", "chat_id", fromChatID, "new_chat_id", toChatID, "jobs", len(movedJobs))
+	return registrationReply{}
+}
+
+// handleGroupUpdate handles an update from a group like one from a private chat, except that
+// only the initiator can answer a registration, only admins can manage alarms if the group is set to admins only,
+// and messages that are not meant for the bot are ignored
+func handleGroupUpdate(update tgbotapi.Update) registrationReply {
+	if update.CallbackQuery != nil {
+		return handleGroupCallback(update)
+	}
+
+	message := update.Message
+	chatID := message.Chat.ID
+	if message.From == nil {
+		return registrationReply{}
+	}
+	userState, err := userStateDB.GetUserState(chatID)
+	if err != nil {
+		return errorReply(chatID, err)
+	}
+	isInitiator := isRegistrationInitiator(userState, message.From)
+
+	if !message.IsCommand() {
+		// Answers have to be replies to the bot, so that they are delivered in privacy mode and the rest of the group's chatter is not answered
+		isReplyToBot := message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.ID == bot.Self.ID
+		isAnswer := userState != nil && isInitiator
+		isImport := userState == nil && message.Document != nil
+		if !isReplyToBot || !(isAnswer || isImport) {
+			return registrationReply{}
+		}
+		if isImport {
+			mayManage, err := mayManageAlarms(chatID, message.From.ID)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+			if !mayManage {
+				return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(updateLanguage(update), "group.adminsOnly"))}
+			}
+		}
+		return addressReply(update, handleUpdate(update))
+	}
+
+	commandWithAt := message.CommandWithAt()
+	if i := strings.Index(commandWithAt, "@"); i != -1 && !strings.EqualFold(commandWithAt[i+1:], bot.Self.UserName) {
+		return registrationReply{}
+	}
+
+	command := message.Command()
+	if registrationCommands[command] && userState != nil && !isInitiator {
+		// Group admins can stop a registration that was abandoned, which would otherwise hold up the group
+		isAdminExit := false
+		if command == "exit" {
+			isAdminExit, err = isChatAdmin(chatID, message.From.ID)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+		}
+		if !isAdminExit {
+			text := translate(updateLanguage(update), "group.inProgress", userState.InitiatorName)
+			return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, text)}
+		}
+	}
+	if managementCommands[command] {
+		mayManage, err := mayManageAlarms(chatID, message.From.ID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if !mayManage {
+			return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(updateLanguage(update), "group.adminsOnly"))}
+		}
+	}
+	return addressReply(update, handleUpdate(update))
+}
+
+// handleGroupCallback only lets the initiator answer a registration, and admins change settings that are for admins
+func handleGroupCallback(update tgbotapi.Update) registrationReply {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callBackID := update.CallbackQuery.ID
+	from := update.CallbackQuery.From
+
+	switch {
+	case isNotificationCallback(update):
+
+	case isSettingsUpdate(update):
+		if update.CallbackQuery.Data != settingAdminsOnly && !preferencesOrDefault(chatID).AdminsOnly {
+			break
+		}
+		isAdmin, err := isChatAdmin(chatID, from.ID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if !isAdmin {
+			return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(updateLanguage(update), "group.adminsOnlyButton"))}
+		}
+
+	default:
+		userState, err := userStateDB.GetUserState(chatID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if userState != nil && !isRegistrationInitiator(userState, from) {
+			return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(updateLanguage(update), "group.onlyInitiator", userState.InitiatorName))}
+		}
+		// The group may have been set to admins only since the import was previewed
+		if update.CallbackQuery.Data == importConfirm {
+			mayManage, err := mayManageAlarms(chatID, from.ID)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+			if !mayManage {
+				return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(updateLanguage(update), "group.adminsOnlyButton"))}
+			}
+		}
+		return addressReply(update, handleUpdate(update))
+	}
+	return handleUpdate(update)
+}
+
+// isRegistrationInitiator returns true if the user started the registration.
+// Registrations from before initiators were kept can be answered by anyone
+func isRegistrationInitiator(userState *UserState, user *tgbotapi.User) bool {
+	return userState != nil && (userState.InitiatorID == 0 || userState.InitiatorID == user.ID)
+}
+
+// addressReply addresses a question in the registration to the initiator, by replying to their message or mentioning them,
+// and asks their client to reply to it. Only the initiator is asked, and their answer reaches the bot in privacy mode.
+// Questions with buttons are answered with the buttons, so they are only addressed to the initiator
+func addressReply(update tgbotapi.Update, reply registrationReply) registrationReply {
+	if reply.background != nil {
+		background := reply.background
+		reply.background = func() registrationReply { return addressReply(update, background()) }
+	}
+	replyMessage, ok := reply.replyMessage.(tgbotapi.MessageConfig)
+	if !ok {
+		return reply
+	}
+	_, hasButtons := replyMessage.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup)
+	if replyMessage.ReplyMarkup != nil && !hasButtons {
+		return reply
+	}
+	userState, err := userStateDB.GetUserState(replyMessage.ChatID)
+	if err != nil || userState == nil {
+		return reply
+	}
+	if update.Message != nil {
+		replyMessage.ReplyToMessageID = update.Message.MessageID
+	} else if userState.InitiatorName != "" {
+		replyMessage.Text = userState.InitiatorName + " " + replyMessage.Text
+	}
+	if !hasButtons {
+		replyMessage.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	}
+	reply.replyMessage = replyMessage
+	return reply
+}
+
+// mayManageAlarms returns true unless the group is set to admins only and the user is not an admin
+func mayManageAlarms(chatID int64, userID int) (bool, error) {
+	if !preferencesOrDefault(chatID).AdminsOnly {
+		return true, nil
+	}
+	return isChatAdmin(chatID, userID)
+}
+
+// isChatAdmin returns true if the user is the creator or an administrator of the group
+func isChatAdmin(chatID int64, userID int) (bool, error) {
+	var member tgbotapi.ChatMember
+	err := observeTelegramRequest("getChatMember", func() error {
+		var err error
+		member, err = bot.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID})
+		return err
+	})
+	return member.IsCreator() || member.IsAdministrator(), err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/robfig/cron/v3"
+)
+
+const testGroupID int64 = -100
+
+// fakeChatMembers answers getChatMember with the status of the user, as the Telegram bot API does
+type fakeChatMembers map[string]string
+
+func (m fakeChatMembers) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.ParseForm()
+	body := `{"ok":true,"result":{"user":{"id":` + r.Form.Get("user_id") + `},"status":"` + m[r.Form.Get("user_id")] + `"}}`
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+}
+
+func setUpGroup() func() {
+	memoryStore := NewMemoryStore()
+	storedJobDB, userStateDB, preferencesDB = memoryStore.Jobs, memoryStore.UserStates, memoryStore.Preferences
+	busServiceLookUp = map[string]bool{"506": true}
+	bot = &tgbotapi.BotAPI{
+		Token:  "123:abc",
+		Self:   tgbotapi.User{ID: 99, UserName: "BusBot"},
+		Client: &http.Client{Transport: fakeChatMembers{"1": "creator", "2": "member"}},
+	}
+	return func() {
+		storedJobDB, userStateDB, preferencesDB, busServiceLookUp, bot = nil, nil, nil, nil, nil
+	}
+}
+
+func groupMessage(from int, text string, replyToBot bool) tgbotapi.Update {
+	update := commandUpdate(testGroupID, text)
+	update.Message.Chat.Type = "supergroup"
+	update.Message.From = &tgbotapi.User{ID: from, FirstName: "User"}
+	if !strings.HasPrefix(text, "/") {
+		update.Message.Entities = nil
+	}
+	if replyToBot {
+		update.Message.ReplyToMessage = &tgbotapi.Message{From: &bot.Self}
+	}
+	return update
+}
+
+func replyText(reply registrationReply) string {
+	if message, ok := reply.replyMessage.(tgbotapi.MessageConfig); ok {
+		return message.Text
+	}
+	return ""
+}
+
+func TestGroupRegistrationIsAnsweredByTheInitiator(t *testing.T) {
+	defer setUpGroup()()
+
+	if reply := routeUpdate(groupMessage(1, "/register@OtherBot", false)); reply.replyMessage != nil {
+		t.Errorf("Expected a command for another bot to be ignored but got %v", reply)
+	}
+	reply := routeUpdate(groupMessage(1, "/register@BusBot", false))
+	if forceReply, ok := reply.replyMessage.(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.ForceReply); !ok || !forceReply.Selective {
+		t.Errorf("Expected the question to be addressed to the initiator but got %v", reply.replyMessage)
+	}
+
+	if reply := routeUpdate(groupMessage(2, "506", true)); reply.replyMessage != nil {
+		t.Errorf("Expected another member's answer to be ignored but got %v", reply)
+	}
+	if reply := routeUpdate(groupMessage(1, "506", false)); reply.replyMessage != nil {
+		t.Errorf("Expected a message that does not reply to the bot to be ignored but got %v", reply)
+	}
+	if text := replyText(routeUpdate(groupMessage(2, "/exit", false))); !strings.Contains(text, "User is in the middle of registering") {
+		t.Errorf("Expected another member's /exit to be refused but got %q", text)
+	}
+
+	routeUpdate(groupMessage(1, "506", true))
+	if userState, _ := userStateDB.GetUserState(testGroupID); userState == nil || userState.State != 2 || userState.InitiatorID != 1 {
+		t.Errorf("Expected the initiator's answer to be handled but got %v", userState)
+	}
+}
+
+func TestGroupTimePickerIsAddressedToTheInitiator(t *testing.T) {
+	defer setUpGroup()()
+
+	userState := UserState{BusInfoJob: BusInfoJob{BusServiceNo: "506", BusStopCode: "43411"}, State: 3, SelectedDays: make(map[time.Weekday]bool), InitiatorID: 1, InitiatorName: "User"}
+	userState.ToggleDay(time.Monday)
+	userStateDB.SaveUserState(testGroupID, userState)
+	done := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		From:    &tgbotapi.User{ID: 1},
+		Data:    "-1",
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: testGroupID, Type: "supergroup"}},
+	}}
+	reply := routeUpdate(done)
+	message, ok := reply.replyMessage.(tgbotapi.MessageConfig)
+	if !ok || !strings.HasPrefix(message.Text, "User ") {
+		t.Fatalf("Expected the time picker to mention the initiator but got %v", reply.replyMessage)
+	}
+	if _, ok := message.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup); !ok {
+		t.Errorf("Expected the time picker to be kept but got %v", message.ReplyMarkup)
+	}
+}
+
+func TestGroupAdminCanExitAbandonedRegistration(t *testing.T) {
+	defer setUpGroup()()
+
+	routeUpdate(groupMessage(2, "/register", false))
+	if text := replyText(routeUpdate(groupMessage(1, "/register", false))); !strings.Contains(text, "User is in the middle of registering") {
+		t.Errorf("Expected the admin to wait for the registration in progress but got %q", text)
+	}
+	routeUpdate(groupMessage(1, "/exit", false))
+	if userState, _ := userStateDB.GetUserState(testGroupID); userState != nil {
+		t.Fatalf("Expected the admin to stop the registration but got %v", userState)
+	}
+	routeUpdate(groupMessage(1, "/register", false))
+	if userState, _ := userStateDB.GetUserState(testGroupID); userState == nil || userState.InitiatorID != 1 {
+		t.Errorf("Expected the admin to register once the registration was stopped but got %v", userState)
+	}
+}
+
+func TestGroupAdminsOnly(t *testing.T) {
+	defer setUpGroup()()
+
+	settingsUpdate := func(from int) tgbotapi.Update {
+		return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: from},
+			Data:    settingAdminsOnly,
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: testGroupID, Type: "group"}},
+		}}
+	}
+	if reply := routeUpdate(settingsUpdate(2)); reply.callbackResponse.Text != "Only the group's admins can change this" {
+		t.Errorf("Expected a member not to change who manages alarms but got %v", reply)
+	}
+	routeUpdate(settingsUpdate(1))
+	if preferences, _ := preferencesDB.GetPreferences(testGroupID); !preferences.AdminsOnly {
+		t.Fatal("Expected the creator to set the group to admins only")
+	}
+
+	if text := replyText(routeUpdate(groupMessage(2, "/register", false))); text != "Only the group's admins can manage alarms in this group" {
+		t.Errorf("Expected a member not to register but got %q", text)
+	}
+	routeUpdate(groupMessage(1, "/register", false))
+	if userState, _ := userStateDB.GetUserState(testGroupID); userState == nil {
+		t.Error("Expected the creator to register")
+	}
+}
+
+func TestGroupAdminsOnlyImport(t *testing.T) {
+	defer setUpGroup()()
+	preferencesDB.SavePreferences(testGroupID, Preferences{AdminsOnly: true})
+
+	update := groupMessage(2, "bus-alarms.json", true)
+	update.Message.Text = ""
+	update.Message.Document = &tgbotapi.Document{FileID: "file", FileName: "bus-alarms.json", FileSize: 100}
+	if text := replyText(routeUpdate(update)); text != "Only the group's admins can manage alarms in this group" {
+		t.Errorf("Expected a member not to import a document but got %q", text)
+	}
+
+	// A preview from before the group was set to admins only cannot be confirmed by a member
+	userStateDB.SaveUserState(testGroupID, UserState{State: 6, InitiatorID: 2, ImportedJobs: []BusInfoJob{
+		{ChatID: testGroupID, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}},
+	}})
+	confirm := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		From:    &tgbotapi.User{ID: 2},
+		Data:    importConfirm,
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: testGroupID, Type: "supergroup"}},
+	}}
+	if reply := routeUpdate(confirm); reply.callbackResponse.Text != "Only the group's admins can change this" {
+		t.Errorf("Expected a member not to confirm the import but got %v", reply)
+	}
+	if jobs, _ := storedJobDB.GetJobsByChatID(testGroupID); len(jobs) != 0 {
+		t.Errorf("Expected nothing to be imported but got %v", jobs)
+	}
+}
+
+func TestMigrationMovesTheGroup(t *testing.T) {
+	defer setUpGroup()()
+	cronner = cron.New()
+	defer func() { cronner = nil }()
+
+	job, _ := storedJobDB.StoreJob(BusInfoJob{ChatID: testGroupID, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{now().Weekday()}})
+	addJobtoCronner(cronner, job)
+	preferencesDB.SavePreferences(testGroupID, Preferences{AdminsOnly: true})
+
+	update := tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: testGroupID, Type: "group"}, MigrateToChatID: -1001}}
+	routeUpdate(update)
+
+	if jobs, _ := storedJobDB.GetJobsByChatID(-1001); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("Expected the alarm to move to the supergroup but got %v", jobs)
+	}
+	if preferences, _ := preferencesDB.GetPreferences(-1001); !preferences.AdminsOnly {
+		t.Error("Expected the settings to move to the supergroup")
+	}
+	entries := cronner.Entries()
+	if len(entries) != 1 || entries[0].Job.(alarmCronJob).ChatID != -1001 {
+		t.Errorf("Expected today's alarm to be posted to the supergroup but got %v", entries)
+	}
+}
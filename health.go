@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Updates are long polled for 60 seconds at a time, so a successful getUpdates is expected at least this often
+const updatesStaleAfter time.Duration = 3 * time.Minute
+
+// How long readiness waits for cron to list its entries, cron is stuck if it does not answer in time
+const cronCheckTimeout time.Duration = time.Second
+
+// How long the webhook info from Telegram is reused for, so that readiness probes do not call Telegram every time
+const webhookInfoCacheDuration time.Duration = 30 * time.Second
+
+// Unix nanoseconds of the last successful getUpdates or webhook delivery
+var lastUpdatesSuccess int64
+
+// recordUpdatesSuccess marks that updates were just received from Telegram
+func recordUpdatesSuccess() {
+	atomic.StoreInt64(&lastUpdatesSuccess, time.Now().UnixNano())
+}
+
+// updatesTracker is the transport of the bot's HTTP client, which records every successful getUpdates
+type updatesTracker struct {
+	next http.RoundTripper
+}
+
+func (t updatesTracker) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(r)
+	if err == nil && res.StatusCode == http.StatusOK && strings.HasSuffix(r.URL.Path, "/getUpdates") {
+		recordUpdatesSuccess()
+	}
+	return res, err
+}
+
+// webhookInfoCache holds the number of updates that Telegram has waiting to deliver to the webhook
+type webhookInfoCache struct {
+	mutex        sync.Mutex
+	fetchedAt    time.Time
+	pendingCount int
+	err          error
+}
+
+var pendingWebhookUpdates = &webhookInfoCache{}
+
+// PendingUpdateCount returns the number of updates waiting to be delivered, asking Telegram at most once per webhookInfoCacheDuration
+func (c *webhookInfoCache) PendingUpdateCount() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Since(c.fetchedAt) > webhookInfoCacheDuration {
+		webhookInfo, err := bot.GetWebhookInfo()
+		c.fetchedAt, c.pendingCount, c.err = time.Now(), webhookInfo.PendingUpdateCount, err
+	}
+	return c.pendingCount, c.err
+}
+
+// handleHealthz answers as long as the process is serving requests
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz answers 503 with the failed checks when the bot cannot serve its users
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	failures := checkReadiness()
+	if len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, failure := range failures {
+			fmt.Fprintln(w, failure)
+		}
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// checkReadiness returns what is stopping the bot from serving its users
+func checkReadiness() []string {
+	failures := []string{}
+	if err := store.Ping(); err != nil {
+		failures = append(failures, fmt.Sprintf("stores: %v", err))
+	}
+	if err := checkRefData(); err != nil {
+		failures = append(failures, fmt.Sprintf("refdata: %v", err))
+	}
+	if err := checkCron(); err != nil {
+		failures = append(failures, fmt.Sprintf("cron: %v", err))
+	}
+	if err := checkUpdates(); err != nil {
+		failures = append(failures, fmt.Sprintf("updates: %v", err))
+	}
+	return failures
+}
+
+func checkRefData() error {
+	if len(busServiceLookUp) == 0 {
+		return fmt.Errorf("no bus services loaded from %s", config.BusServicesFile)
+	}
+	hasBusRoutes, err := refDataDB.HasBusRoutes()
+	if err != nil {
+		return err
+	}
+	if !hasBusRoutes {
+		return fmt.Errorf("no bus routes in %s", config.RefDataDBFile)
+	}
+	return nil
+}
+
+// checkCron checks that cron answers, and that the midnight refresh is scheduled in the future.
+// A cron that has stopped running jobs leaves the refresh scheduled in the past
+func checkCron() error {
+	if cronner == nil {
+		return fmt.Errorf("not started")
+	}
+	entries := make(chan []cronEntry, 1)
+	go func() {
+		cronEntries := []cronEntry{}
+		for _, entry := range cronner.Entries() {
+			cronEntries = append(cronEntries, cronEntry{entry.ID == refreshCronEntryID, entry.Next})
+		}
+		entries <- cronEntries
+	}()
+
+	select {
+	case <-time.After(cronCheckTimeout):
+		return fmt.Errorf("did not list its entries within %s", cronCheckTimeout)
+	case cronEntries := <-entries:
+		for _, entry := range cronEntries {
+			if entry.isRefresh {
+				if !entry.next.After(time.Now()) {
+					return fmt.Errorf("midnight refresh is overdue since %s", entry.next.Format(time.RFC3339))
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("midnight refresh is not scheduled")
+	}
+}
+
+type cronEntry struct {
+	isRefresh bool
+	next      time.Time
+}
+
+// checkUpdates checks that updates were received from Telegram recently.
+// A webhook only receives updates when there are any, so it is only stuck if Telegram has updates waiting
+func checkUpdates() error {
+	lastSuccess := time.Unix(0, atomic.LoadInt64(&lastUpdatesSuccess))
+	if time.Since(lastSuccess) <= updatesStaleAfter {
+		return nil
+	}
+	if config.UpdateMode != "webhook" {
+		return fmt.Errorf("no successful getUpdates within %s", updatesStaleAfter)
+	}
+
+	pendingCount, err := pendingWebhookUpdates.PendingUpdateCount()
+	if err != nil {
+		return fmt.Errorf("unable to get webhook info: %v", err)
+	}
+	if pendingCount > 0 {
+		return fmt.Errorf("%d updates are waiting but none were delivered within %s", pendingCount, updatesStaleAfter)
+	}
+	return nil
+}
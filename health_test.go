@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bus-notifier/refdata"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestUpdatesTrackerRecordsGetUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":[]}`))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: updatesTracker{http.DefaultTransport}}
+
+	atomic.StoreInt64(&lastUpdatesSuccess, 0)
+	client.PostForm(server.URL+"/bot123/sendMessage", nil)
+	if atomic.LoadInt64(&lastUpdatesSuccess) != 0 {
+		t.Errorf("sendMessage should not be recorded as receiving updates")
+	}
+	client.PostForm(server.URL+"/bot123/getUpdates", nil)
+	if atomic.LoadInt64(&lastUpdatesSuccess) == 0 {
+		t.Errorf("getUpdates should be recorded as receiving updates")
+	}
+}
+
+func TestCheckReadiness(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config = &Config{UpdateMode: "polling", BusServicesFile: "bus_services.txt", RefDataDBFile: filepath.Join(dir, "refdata.db")}
+	store = NewMemoryStore()
+	busServiceLookUp = map[string]bool{"506": true}
+	refDataDB, err = refdata.OpenRefDataDB(config.RefDataDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refDataDB.StoreBusRoutes([]refdata.BusRoute{{BusServiceNo: "506", BusStop: refdata.BusStop{BusStopCode: "43411"}, Direction: 1, StopSequence: 1}})
+	cronner = cron.New()
+	refreshCronEntryID, _ = cronner.AddFunc("0 0 * * *", func() {})
+	cronner.Start()
+	defer func() {
+		cronner.Stop()
+		refDataDB.Close()
+		config, store, busServiceLookUp, cronner = nil, nil, nil, nil
+	}()
+
+	recordUpdatesSuccess()
+	if failures := checkReadiness(); len(failures) != 0 {
+		t.Errorf("Expected the bot to be ready but got %v", failures)
+	}
+
+	atomic.StoreInt64(&lastUpdatesSuccess, 0)
+	busServiceLookUp = map[string]bool{}
+	failures := checkReadiness()
+	if len(failures) != 2 || !strings.HasPrefix(failures[0], "refdata:") || !strings.HasPrefix(failures[1], "updates:") {
+		t.Errorf("Expected refdata and updates to fail but got %v", failures)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Language of users whose Telegram app is in a language that the bot does not speak
+const defaultLanguage string = "en"
+
+// Languages that the bot speaks by their ISO 639-1 code, in the order that /language lists them
+var languages = []string{"en", "zh", "ms", "ta"}
+
+// messageCatalogue holds the user-facing messages of each language by message ID.
+// Messages that are missing from a language are shown in English
+var messageCatalogue = map[string]map[string]string{
+	"en": messagesEN,
+	"zh": messagesZH,
+	"ms": messagesMS,
+	"ta": messagesTA,
+}
+
+// translate returns the message in the language, formatted with the arguments like fmt.Sprintf
+func translate(language string, messageID string, args ...interface{}) string {
+	message, ok := messageCatalogue[language][messageID]
+	if !ok {
+		message, ok = messageCatalogue[defaultLanguage][messageID]
+	}
+	if !ok {
+		logError("Missing message", "message_id", messageID)
+		return messageID
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// supportedLanguage returns the language that the bot speaks for a Telegram language_code such as "zh-hans",
+// or an empty string if the bot does not speak it
+func supportedLanguage(languageCode string) string {
+	code := strings.ToLower(strings.SplitN(languageCode, "-", 2)[0])
+	if _, ok := messageCatalogue[code]; ok {
+		return code
+	}
+	return ""
+}
+
+// languageOf returns the language that the chat chose with /language, or else the language of the user's Telegram app
+func languageOf(preferences Preferences) string {
+	if preferences.Language != "" {
+		return preferences.Language
+	}
+	if preferences.LanguageCode != "" {
+		return preferences.LanguageCode
+	}
+	return defaultLanguage
+}
+
+// updateLanguage returns the language to reply to the update in.
+// Without a language chosen with /language, the reply is in the language of the sender's Telegram app
+func updateLanguage(update tgbotapi.Update) string {
+	chatID, from := updateChatAndSender(update)
+	preferences := preferencesOrDefault(chatID)
+	if preferences.Language != "" {
+		return preferences.Language
+	}
+	if from != nil && supportedLanguage(from.LanguageCode) != "" {
+		return supportedLanguage(from.LanguageCode)
+	}
+	return languageOf(preferences)
+}
+
+// rememberLanguageCode keeps the language of the user's Telegram app, so that alarms are sent in the same language as replies.
+// Groups are used by people with different languages, so only the language chosen with /language is used for them
+func rememberLanguageCode(update tgbotapi.Update) {
+	chatID, from := updateChatAndSender(update)
+	if from == nil || int64(from.ID) != chatID {
+		return
+	}
+	languageCode := supportedLanguage(from.LanguageCode)
+	preferences := preferencesOrDefault(chatID)
+	if languageCode == "" || languageCode == preferences.LanguageCode {
+		return
+	}
+	preferences.LanguageCode = languageCode
+	if err := preferencesDB.SavePreferences(chatID, preferences); err != nil {
+		logWarn("Unable to save language", "chat_id", chatID, "err", err)
+	}
+}
+
+// updateChatAndSender returns the chat that the message or callback is from, and the user who sent it
+func updateChatAndSender(update tgbotapi.Update) (int64, *tgbotapi.User) {
+	if update.CallbackQuery != nil {
+		return update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.From
+	}
+	return update.Message.Chat.ID, update.Message.From
+}
+
+// weekdayName returns the name of the day in the language
+func weekdayName(language string, day time.Weekday) string {
+	return translate(language, fmt.Sprintf("weekday.%d", day))
+}
+
+// shortWeekdayName returns the abbreviated name of the day in the language, for buttons
+func shortWeekdayName(language string, day time.Weekday) string {
+	return translate(language, fmt.Sprintf("weekdayShort.%d", day))
+}
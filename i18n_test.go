@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+var formatVerb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-z]`)
+
+// exampleArgs returns arguments of the types that the English message formats
+func exampleArgs(message string) []interface{} {
+	args := []interface{}{}
+	for _, verb := range formatVerb.FindAllString(message, -1) {
+		switch verb[len(verb)-1] {
+		case 'd':
+			args = append(args, 1)
+		case 'f':
+			args = append(args, 1.0)
+		default:
+			args = append(args, "x")
+		}
+	}
+	return args
+}
+
+func TestEveryLanguageHasEveryMessage(t *testing.T) {
+	for _, language := range languages {
+		for messageID, message := range messageCatalogue[defaultLanguage] {
+			translated, ok := messageCatalogue[language][messageID]
+			if !ok {
+				t.Errorf("%s is missing %s", language, messageID)
+				continue
+			}
+			args := exampleArgs(message)
+			if len(args) == 0 {
+				continue
+			}
+			if formatted := translate(language, messageID, args...); strings.Contains(formatted, "%!") {
+				t.Errorf("%s %s does not take the same arguments as English: %s", language, messageID, formatted)
+			}
+			if !strings.Contains(translated, "%") {
+				t.Errorf("%s %s leaves out the arguments: %s", language, messageID, translated)
+			}
+		}
+		for messageID := range messageCatalogue[language] {
+			if _, ok := messageCatalogue[defaultLanguage][messageID]; !ok {
+				t.Errorf("%s has %s which is not in English", language, messageID)
+			}
+		}
+	}
+}
+
+func TestSupportedLanguage(t *testing.T) {
+	for languageCode, expected := range map[string]string{"en": "en", "en-GB": "en", "zh-hans": "zh", "ms": "ms", "ta": "ta", "fr": "", "": ""} {
+		if language := supportedLanguage(languageCode); language != expected {
+			t.Errorf("Expected %q for %q but got %q", expected, languageCode, language)
+		}
+	}
+}
+
+func TestUpdateLanguagePrefersChosenLanguage(t *testing.T) {
+	preferencesDB = NewMemoryStore().Preferences
+	defer func() { preferencesDB = nil }()
+
+	update := tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: 7, Type: "private"},
+		From: &tgbotapi.User{ID: 7, LanguageCode: "zh-hans"},
+		Text: "hello",
+	}}
+	if language := updateLanguage(update); language != "zh" {
+		t.Errorf("Expected the language of the Telegram app but got %s", language)
+	}
+
+	rememberLanguageCode(update)
+	if preferences := preferencesOrDefault(7); languageOf(preferences) != "zh" {
+		t.Errorf("Expected the language of the Telegram app to be kept for alarms but got %+v", preferences)
+	}
+
+	preferencesDB.SavePreferences(7, Preferences{Language: "ta", LanguageCode: "zh"})
+	if language := updateLanguage(update); language != "ta" {
+		t.Errorf("Expected the language chosen with /language but got %s", language)
+	}
+}
+
+func TestLocalisedWeekdays(t *testing.T) {
+	days := []time.Weekday{time.Monday, time.Friday}
+	for language, expected := range map[string]string{"en": "Monday, Friday", "zh": "星期一、星期五"} {
+		if joined := joinDaysString(language, days); joined != expected {
+			t.Errorf("Expected %q in %s but got %q", expected, language, joined)
+		}
+	}
+	if name := weekdayName("ms", time.Sunday); name != "Ahad" {
+		t.Errorf("Expected Ahad but got %s", name)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const importCallbackPrefix string = "import:"
+
+const (
+	importConfirm string = importCallbackPrefix + "confirm"
+	importCancel  string = importCallbackPrefix + "cancel"
+)
+
+// Largest uploaded export that is read, an export of every alarm that a user could reasonably have is far smaller
+const maxImportFileSize int = 256 * 1024
+
+// Time given to find and download an uploaded export
+const downloadTimeout time.Duration = 30 * time.Second
+
+// Most alarms that can be imported at once
+const maxImportedJobs int = 50
+
+// Name of the document that /export sends
+const exportFileName string = "bus-alarms.json"
+
+// isImportUpdate returns true if the update is /export, /import, an uploaded document, or a tap on the import preview
+func isImportUpdate(update tgbotapi.Update) bool {
+	if update.CallbackQuery != nil {
+		return strings.HasPrefix(update.CallbackQuery.Data, importCallbackPrefix)
+	}
+	message := update.Message
+	if message.Document != nil {
+		return true
+	}
+	return message.IsCommand() && (message.Command() == "export" || message.Command() == "import")
+}
+
+// handleImport sends the user's alarms as a document, previews the alarms in an uploaded document,
+// and creates the previewed alarms once the user confirms
+func handleImport(update tgbotapi.Update) registrationReply {
+	if update.CallbackQuery != nil {
+		return handleImportCallback(update)
+	}
+
+	message := update.Message
+	chatID := message.Chat.ID
+	language := updateLanguage(update)
+	switch {
+	case message.Document != nil:
+		if message.Document.FileSize > maxImportFileSize {
+			return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(language, "import.tooLarge"))}
+		}
+		// The document is downloaded apart from the update loop, so that a slow download does not hold up other updates
+		fileID, from := message.Document.FileID, message.From
+		return registrationReply{background: func() registrationReply {
+			contents, err := downloadDocument(fileID)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+			return previewImport(chatID, from, language, contents)
+		}}
+
+	case message.Command() == "export":
+		jobs, err := storedJobDB.GetJobsByChatID(chatID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if len(jobs) == 0 {
+			return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(language, "noAlarms"))}
+		}
+		buffer := bytes.Buffer{}
+		if err := exportJobs(&buffer, jobs); err != nil {
+			return errorReply(chatID, err)
+		}
+		document := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: exportFileName, Bytes: buffer.Bytes()})
+		document.Caption = translate(language, "export.caption", len(jobs))
+		return registrationReply{replyMessage: document}
+
+	default:
+		reply := tgbotapi.NewMessage(chatID, translate(language, "import.ask"))
+		if isGroupChat(message.Chat) {
+			// Documents in groups are only read if they reply to the bot, so that other files shared in the group are not imported
+			reply.Text = translate(language, "import.askInGroup")
+			reply.ReplyToMessageID = message.MessageID
+			reply.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+		}
+		return registrationReply{replyMessage: reply}
+	}
+}
+
+// downloadDocument returns the contents of a document that was uploaded to the chat
+func downloadDocument(fileID string) ([]byte, error) {
+	// bot.Client waits on long polling for updates, so the document is downloaded with a client that gives up after downloadTimeout
+	fileBot := *bot
+	fileBot.Client = &http.Client{Transport: bot.Client.Transport, Timeout: downloadTimeout}
+	var contents []byte
+	err := observeTelegramRequest("getFile", func() error {
+		fileURL, err := fileBot.GetFileDirectURL(fileID)
+		if err != nil {
+			return err
+		}
+		res, err := fileBot.Client.Get(fileURL)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("Unable to download file: %s", res.Status)
+		}
+		contents, err = ioutil.ReadAll(io.LimitReader(res.Body, int64(maxImportFileSize)+1))
+		return err
+	})
+	if err == nil && len(contents) > maxImportFileSize {
+		return nil, errors.New("Uploaded file is too large")
+	}
+	return contents, err
+}
+
+// previewImport lists the alarms in the export that can be set up for the chat, and the ones that cannot with the reason why.
+// The alarms are kept in the user's state until the user confirms or cancels
+func previewImport(chatID int64, from *tgbotapi.User, language string, contents []byte) registrationReply {
+	jobs, err := readJobExport(bytes.NewReader(contents))
+	if err != nil {
+		return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(language, "import.notExport", err))}
+	}
+	if len(jobs) > maxImportedJobs {
+		reply := tgbotapi.NewMessage(chatID, translate(language, "import.tooMany", len(jobs), maxImportedJobs))
+		return registrationReply{replyMessage: reply}
+	}
+
+	importedJobs := []BusInfoJob{}
+	invalid := strings.Builder{}
+	for i, job := range jobs {
+		// The alarms belong to the chat that imports them, and get new IDs when they are stored
+		job.ID = 0
+		job.ChatID = chatID
+		if err := validateImportedJob(language, job); err != nil {
+			fmt.Fprintf(&invalid, "%d. %v\n", i+1, err)
+			continue
+		}
+		importedJobs = append(importedJobs, job)
+	}
+
+	stringBuilder := strings.Builder{}
+	if len(importedJobs) == 0 {
+		stringBuilder.WriteString(translate(language, "import.noneValid"))
+		stringBuilder.WriteString("\n")
+	} else {
+		stringBuilder.WriteString(translate(language, "import.preview", len(importedJobs)))
+		stringBuilder.WriteString("\n")
+		for i, job := range importedJobs {
+			stringBuilder.WriteString(alarmString(language, i+1, job))
+			stringBuilder.WriteString("\n")
+		}
+	}
+	if invalid.Len() > 0 {
+		stringBuilder.WriteString("\n")
+		stringBuilder.WriteString(translate(language, "import.skipping"))
+		stringBuilder.WriteString("\n")
+		stringBuilder.WriteString(invalid.String())
+	}
+	reply := tgbotapi.NewMessage(chatID, truncateMessage(stringBuilder.String()))
+	if len(importedJobs) == 0 {
+		return registrationReply{replyMessage: reply}
+	}
+
+	// Importing replaces a registration that is in progress
+	userState := newUserState(6, from)
+	userState.ImportedJobs = importedJobs
+	if err := userStateDB.SaveUserState(chatID, userState); err != nil {
+		return errorReply(chatID, err)
+	}
+	reply.ReplyMarkup = buildImportKeyboard(language, len(importedJobs))
+	return registrationReply{replyMessage: reply}
+}
+
+// validateImportedJob checks that the bus alarm can be scheduled, and that the bus service stops at the bus stop.
+// Problems with the bus service or bus stop are described in the language, as they are the ones that users can fix
+func validateImportedJob(language string, job BusInfoJob) error {
+	if err := validateJob(job); err != nil {
+		return err
+	}
+	if !busServiceLookUp[job.BusServiceNo] {
+		return errors.New(translate(language, "import.noBus", job.BusServiceNo))
+	}
+	for _, busRoute := range refDataDB.GetBusRoutesByBusService(job.BusServiceNo) {
+		if busRoute.BusStopCode == job.BusStopCode {
+			return nil
+		}
+	}
+	return errors.New(translate(language, "import.noBusStop", job.BusStopCode, job.BusServiceNo))
+}
+
+// handleImportCallback creates the previewed alarms, or forgets them if the user cancels
+func handleImportCallback(update tgbotapi.Update) registrationReply {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	callBackID := update.CallbackQuery.ID
+	language := updateLanguage(update)
+
+	userState, err := userStateDB.GetUserState(chatID)
+	if err != nil {
+		return errorReply(chatID, err)
+	}
+	if userState == nil || userState.State != 6 {
+		return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "import.notWaiting"))}
+	}
+	if update.CallbackQuery.Data != importConfirm {
+		if err := userStateDB.DeleteUserState(chatID); err != nil {
+			return errorReply(chatID, err)
+		}
+		editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, translate(language, "import.cancelled"))
+		return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+	}
+
+	// The import stays waiting until every alarm is stored, so that it can be tried again.
+	// Alarms stored by an earlier try are then found as duplicates, instead of being stored twice
+	duplicates := 0
+	for _, job := range userState.ImportedJobs {
+		storedJob, coveredDays, err := storeOrMergeJob(storedJobDB, job)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if len(coveredDays) == len(job.Weekdays) {
+			duplicates++
+			continue
+		}
+		rescheduleJob(cronner, storedJob)
+	}
+	if err := userStateDB.DeleteUserState(chatID); err != nil {
+		return errorReply(chatID, err)
+	}
+	logInfo("Imported jobs", "chat_id", chatID, "jobs", len(userState.ImportedJobs)-duplicates, "duplicates", duplicates)
+
+	text := translate(language, "import.imported", len(userState.ImportedJobs)-duplicates)
+	if duplicates > 0 {
+		text += "\n" + translate(language, "import.duplicates", duplicates)
+	}
+	editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+}
+
+func buildImportKeyboard(language string, count int) *tgbotapi.InlineKeyboardMarkup {
+	var importKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "import.confirm", count), importConfirm),
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "import.cancel"), importCancel),
+		),
+	)
+	return &importKeyboard
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bus-notifier/refdata"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/robfig/cron/v3"
+)
+
+func importCallbackUpdate(chatID int64, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		Data:    data,
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID}},
+	}}
+}
+
+func TestImportPreviewsThenCreatesAlarms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	memoryStore := NewMemoryStore()
+	storedJobDB, userStateDB, preferencesDB = memoryStore.Jobs, memoryStore.UserStates, memoryStore.Preferences
+	busServiceLookUp = map[string]bool{"506": true}
+	refDataDB, err = refdata.OpenRefDataDB(filepath.Join(dir, "refdata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refDataDB.StoreBusRoutes([]refdata.BusRoute{{BusServiceNo: "506", BusStop: refdata.BusStop{BusStopCode: "43411"}, Direction: 1, StopSequence: 1}})
+	cronner = cron.New()
+	defer func() {
+		refDataDB.Close()
+		storedJobDB, userStateDB, preferencesDB, busServiceLookUp, cronner = nil, nil, nil, nil, nil
+	}()
+
+	contents := `{"Version":1,"Alarms":[
+		{"ID":3,"ChatID":1,"BusStopCode":"43411","BusServiceNo":"506","ScheduledTime":{"Hour":7,"Minute":30},"Weekdays":[1,5]},
+		{"ID":4,"ChatID":1,"BusStopCode":"99999","BusServiceNo":"506","ScheduledTime":{"Hour":8,"Minute":0},"Weekdays":[1]},
+		{"ID":5,"ChatID":1,"BusStopCode":"43411","BusServiceNo":"999","ScheduledTime":{"Hour":8,"Minute":0},"Weekdays":[1]}
+	]}`
+	reply := previewImport(2, &tgbotapi.User{ID: 7, FirstName: "Tan"}, defaultLanguage, []byte(contents))
+	text := reply.replyMessage.(tgbotapi.MessageConfig).Text
+	for _, expected := range []string{"these 1 alarms", "Monday, Friday - 07:30 - Bus 506 @ 43411", "2. bus stop 99999 is not serviced", "3. bus 999 does not exist"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in %s", expected, text)
+		}
+	}
+	if jobs, _ := storedJobDB.GetJobsByChatID(2); len(jobs) != 0 {
+		t.Errorf("Expected no alarms before confirming but got %v", jobs)
+	}
+
+	handleImport(importCallbackUpdate(2, importConfirm))
+	jobs, _ := storedJobDB.GetJobsByChatID(2)
+	if len(jobs) != 1 || jobs[0].ScheduledTime != (ScheduledTime{7, 30}) || !jobs[0].HasWeekday(time.Friday) {
+		t.Errorf("Unexpected imported alarms: %v", jobs)
+	}
+	if userState, _ := userStateDB.GetUserState(2); userState != nil {
+		t.Errorf("Expected the import to be finished but got %v", userState)
+	}
+
+	reply = handleImport(importCallbackUpdate(2, importConfirm))
+	if jobs, _ := storedJobDB.GetJobsByChatID(2); len(jobs) != 1 || reply.replyMessage != nil {
+		t.Errorf("Expected a second confirmation to be ignored but got %v", jobs)
+	}
+}
+
+func TestImportRejectsOtherDocuments(t *testing.T) {
+	reply := previewImport(2, nil, defaultLanguage, []byte("name,bus\nme,506"))
+	if text := reply.replyMessage.(tgbotapi.MessageConfig).Text; !strings.HasPrefix(text, "This is not a file from /export") {
+		t.Errorf("Unexpected reply: %s", text)
+	}
+}
+
+func TestImportDownloadsDocumentInBackground(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	userStateDB, preferencesDB = memoryStore.UserStates, memoryStore.Preferences
+	bot = &tgbotapi.BotAPI{Client: &http.Client{Transport: unreachableServer{}}}
+	defer func() { userStateDB, preferencesDB, bot = nil, nil, nil }()
+
+	update := tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 2, Type: "private"},
+		From:      &tgbotapi.User{ID: 2},
+		Document:  &tgbotapi.Document{FileID: "file", FileSize: 100},
+	}}
+	reply := handleImport(update)
+	if reply.replyMessage != nil || reply.background == nil {
+		t.Fatalf("Expected the document to be downloaded apart from the update loop but got %+v", reply)
+	}
+
+	reply = reply.background()
+	if reply.replyMessage == nil {
+		t.Errorf("Expected the user to be told that the document could not be downloaded")
+	}
+	if userState, _ := userStateDB.GetUserState(2); userState != nil {
+		t.Errorf("Expected nothing to be imported but got %v", userState)
+	}
+}
+
+// failingJobStore fails to store alarms once it has stored storeLimit of them
+type failingJobStore struct {
+	JobStore
+	storeLimit int
+}
+
+func (s *failingJobStore) StoreJob(newBusInfoJob BusInfoJob) (BusInfoJob, error) {
+	if s.storeLimit == 0 {
+		return BusInfoJob{}, errors.New("disk full")
+	}
+	s.storeLimit--
+	return s.JobStore.StoreJob(newBusInfoJob)
+}
+
+func TestFailedImportCanBeConfirmedAgain(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	jobStore := &failingJobStore{JobStore: memoryStore.Jobs, storeLimit: 1}
+	storedJobDB, userStateDB, preferencesDB = jobStore, memoryStore.UserStates, memoryStore.Preferences
+	cronner = cron.New()
+	defer func() {
+		storedJobDB, userStateDB, preferencesDB, cronner = nil, nil, nil, nil
+	}()
+
+	importedJobs := []BusInfoJob{
+		{ChatID: 2, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}},
+		{ChatID: 2, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{18, 0}, Weekdays: []time.Weekday{time.Monday}},
+	}
+	userStateDB.SaveUserState(2, UserState{State: 6, ImportedJobs: importedJobs})
+
+	handleImport(importCallbackUpdate(2, importConfirm))
+	if userState, _ := userStateDB.GetUserState(2); userState == nil || userState.State != 6 {
+		t.Fatalf("Expected the import to still be waiting after failing but got %v", userState)
+	}
+
+	jobStore.storeLimit = 1
+	reply := handleImport(importCallbackUpdate(2, importConfirm))
+	if jobs, _ := storedJobDB.GetJobsByChatID(2); len(jobs) != 2 {
+		t.Errorf("Expected both alarms once but got %v", jobs)
+	}
+	if userState, _ := userStateDB.GetUserState(2); userState != nil {
+		t.Errorf("Expected the import to be finished but got %v", userState)
+	}
+	if text := reply.replyMessage.(tgbotapi.EditMessageTextConfig).Text; !strings.HasPrefix(text, "Imported 1 alarms") {
+		t.Errorf("Unexpected reply: %s", text)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Inline results are cached by Telegram, keep it short as arrival timings change every minute
+const inlineQueryCacheSeconds int = 15
+
+// handleInlineQuery answers "<bus service no> <bus stop code>" with the arrival card of the bus service,
+// and "<bus stop code>" with the arrival cards of all bus services at the bus stop
+func handleInlineQuery(inlineQuery *tgbotapi.InlineQuery) tgbotapi.InlineConfig {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: inlineQuery.ID,
+		Results:       []interface{}{},
+		CacheTime:     inlineQueryCacheSeconds,
+		IsPersonal:    true,
+	}
+
+	var busServiceNo, busStopCode string
+	queryArr := strings.Fields(inlineQuery.Query)
+	switch len(queryArr) {
+	case 1:
+		busStopCode = queryArr[0]
+	case 2:
+		busServiceNo, busStopCode = queryArr[0], queryArr[1]
+		if !busServiceLookUp[busServiceNo] {
+			return answer
+		}
+	default:
+		return answer
+	}
+
+	// Queries are sent as the user types, so only complete bus stop codes are looked up on DataMall
+	if refDataDB.GetBusStopByBusStopCode(busStopCode).BusStopCode == "" {
+		return answer
+	}
+
+	busArrivals, err := fetchBusArrivalsAtBusStop(busStopCode)
+	if err != nil {
+		logWarn("Unable to fetch bus arrivals for inline query", "bus_stop", busStopCode, "err", err)
+		return answer
+	}
+
+	// The user's private chat with the bot has the same ID as the user
+	preferences := preferencesOrDefault(int64(inlineQuery.From.ID))
+	for _, busArrivalInformation := range busArrivals {
+		if busServiceNo != "" && busArrivalInformation.BusServiceNo != busServiceNo {
+			continue
+		}
+		resultID := busArrivalInformation.BusServiceNo + "@" + busArrivalInformation.BusStopCode
+		title := translate(languageOf(preferences), "arrival.title", busArrivalInformation.BusServiceNo, busArrivalInformation.BusStopCode)
+		result := tgbotapi.NewInlineQueryResultArticle(resultID, title, busArrivalInformation.toMessageString(preferences))
+		result.Description = busArrivalInformation.NextBus.toString(preferences)
+		answer.Results = append(answer.Results, result)
+	}
+	return answer
+}
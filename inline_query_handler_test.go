@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bus-notifier/refdata"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// unexpectedRequests fails the test on any HTTP request
+type unexpectedRequests struct {
+	t *testing.T
+}
+
+func (u unexpectedRequests) RoundTrip(r *http.Request) (*http.Response, error) {
+	u.t.Errorf("Unexpected request to %s", r.URL.Host)
+	return nil, errors.New("unexpected request")
+}
+
+func TestInlineQueryForUnknownBusStopIsNotLookedUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	refDataDB, err = refdata.OpenRefDataDB(filepath.Join(dir, "refdata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer refDataDB.Close()
+	refDataDB.StoreBusStops([]refdata.BusStop{{BusStopCode: "43411", Description: "Bef Blk 101"}})
+	busServiceLookUp = map[string]bool{"506": true}
+	defer func() { busServiceLookUp = nil }()
+
+	defaultTransport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = unexpectedRequests{t}
+	defer func() { http.DefaultClient.Transport = defaultTransport }()
+
+	for _, query := range []string{"4", "434", "506 434", "506 4341"} {
+		answer := handleInlineQuery(&tgbotapi.InlineQuery{ID: "query", Query: query, From: &tgbotapi.User{ID: 1}})
+		if len(answer.Results) != 0 || answer.InlineQueryID != "query" {
+			t.Errorf("Expected an empty answer for %q but got %+v", query, answer)
+		}
+	}
+}
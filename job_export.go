@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Version of the export format, increased when the format changes in a way that older versions cannot read
+const jobExportVersion int = 1
+
+// jobExport is the document that bus alarms are exported to and imported from
+type jobExport struct {
+	Version int
+	Alarms  []BusInfoJob
+}
+
+// exportJobs writes the bus alarms as an indented JSON document
+func exportJobs(w io.Writer, jobs []BusInfoJob) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jobExport{Version: jobExportVersion, Alarms: jobs})
+}
+
+// importJobs reads bus alarms that were written by exportJobs, and checks that every alarm is valid
+func importJobs(r io.Reader) ([]BusInfoJob, error) {
+	jobs, err := readJobExport(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, job := range jobs {
+		if err := validateJob(job); err != nil {
+			return nil, fmt.Errorf("Alarm %d is invalid: %v", i+1, err)
+		}
+	}
+	return jobs, nil
+}
+
+// readJobExport reads the bus alarms in a document written by exportJobs, without checking them
+func readJobExport(r io.Reader) ([]BusInfoJob, error) {
+	var document jobExport
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("Unable to read the exported alarms: %v", err)
+	}
+	if document.Version != jobExportVersion {
+		return nil, fmt.Errorf("Unsupported export version %d, expected %d", document.Version, jobExportVersion)
+	}
+	return document.Alarms, nil
+}
+
+// validateJob checks that the bus alarm can be scheduled
+func validateJob(job BusInfoJob) error {
+	if job.ChatID == 0 {
+		return errors.New("missing chat ID")
+	}
+	if job.BusStopCode == "" || job.BusServiceNo == "" {
+		return errors.New("missing bus stop code or bus service number")
+	}
+	if job.ScheduledTime.Hour < 0 || job.ScheduledTime.Hour > 23 || job.ScheduledTime.Minute < 0 || job.ScheduledTime.Minute > 59 {
+		return fmt.Errorf("%s is not a time of day", job.ScheduledTime.ToString())
+	}
+	if len(job.Weekdays) == 0 {
+		return errors.New("no days selected")
+	}
+	seen := make(map[time.Weekday]bool)
+	for _, weekday := range job.Weekdays {
+		if weekday < time.Sunday || weekday > time.Saturday || seen[weekday] {
+			return fmt.Errorf("invalid or repeated day %d", weekday)
+		}
+		seen[weekday] = true
+	}
+	return nil
+}
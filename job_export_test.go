@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportAndImportJobs(t *testing.T) {
+	jobs := []BusInfoJob{
+		{ID: 3, ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday, time.Friday}},
+	}
+	buffer := bytes.Buffer{}
+	if err := exportJobs(&buffer, jobs); err != nil {
+		t.Fatal(err)
+	}
+
+	importedJobs, err := importJobs(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(importedJobs) != 1 || importedJobs[0].ChatID != 12345 || importedJobs[0].ScheduledTime != jobs[0].ScheduledTime || !importedJobs[0].HasWeekday(time.Friday) {
+		t.Errorf("Unexpected imported jobs: %v", importedJobs)
+	}
+}
+
+func TestImportRejectsInvalidJobs(t *testing.T) {
+	documents := []string{
+		`{"Version":2,"Alarms":[]}`,
+		`{"Version":1,"Alarms":[{"ChatID":1,"BusStopCode":"43411","BusServiceNo":"506","ScheduledTime":{"Hour":24,"Minute":0},"Weekdays":[1]}]}`,
+		`{"Version":1,"Alarms":[{"ChatID":1,"BusStopCode":"43411","BusServiceNo":"506","ScheduledTime":{"Hour":7,"Minute":0},"Weekdays":[]}]}`,
+		`{"Version":1,"Alarms":[{"ChatID":1,"BusStopCode":"43411","BusServiceNo":"506","ScheduledTime":{"Hour":7,"Minute":0},"Weekdays":[1,1]}]}`,
+		`{"Version":1,"Alarms":[{"ChatID":0,"BusStopCode":"43411","BusServiceNo":"506","ScheduledTime":{"Hour":7,"Minute":0},"Weekdays":[1]}]}`,
+		`not json`,
+	}
+	for _, document := range documents {
+		if _, err := importJobs(strings.NewReader(document)); err == nil {
+			t.Errorf("Expected an error importing %s", document)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// logLevel is the severity of a log entry, entries below the configured level are not written.
+// Message text and other personal information is only logged at debug level
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+// parseLogLevel returns the level with the given name
+func parseLogLevel(name string) (logLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return logLevel(level), nil
+		}
+	}
+	return logLevelInfo, fmt.Errorf("%q is not one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+// Written in place of secrets that would otherwise be logged, such as the bot token in the URL of a failed request
+const redacted string = "[REDACTED]"
+
+// structuredLogger writes one line per entry, as logfmt or as JSON, with the fields given as alternating keys and values
+type structuredLogger struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	level   logLevel
+	json    bool
+	secrets []string
+}
+
+var logger = &structuredLogger{out: os.Stderr, level: logLevelInfo}
+
+func init() {
+	// Anything logged by packages that cannot be given a logger still goes through the structured log, without secrets
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+	tgbotapi.SetLogger(telegramLogger{})
+}
+
+// configureLogging sets the level and format of the log, and the secrets that are never written to it
+func configureLogging(level logLevel, format string, secrets ...string) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.level = level
+	logger.json = format == "json"
+	logger.secrets = nil
+	for _, secret := range secrets {
+		if secret != "" {
+			logger.secrets = append(logger.secrets, secret)
+		}
+	}
+}
+
+func logDebug(msg string, keysAndValues ...interface{}) {
+	logger.log(logLevelDebug, msg, keysAndValues)
+}
+
+func logInfo(msg string, keysAndValues ...interface{}) {
+	logger.log(logLevelInfo, msg, keysAndValues)
+}
+
+func logWarn(msg string, keysAndValues ...interface{}) {
+	logger.log(logLevelWarn, msg, keysAndValues)
+}
+
+func logError(msg string, keysAndValues ...interface{}) {
+	logger.log(logLevelError, msg, keysAndValues)
+}
+
+// logFatal logs at error level, then exits
+func logFatal(msg string, keysAndValues ...interface{}) {
+	logger.log(logLevelError, msg, keysAndValues)
+	os.Exit(1)
+}
+
+// isDebugLogged returns true if debug entries are written, for skipping work that is only needed for them
+func isDebugLogged() bool {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	return logger.level == logLevelDebug
+}
+
+func (l *structuredLogger) log(level logLevel, msg string, keysAndValues []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if level < l.level {
+		return
+	}
+
+	keys := []string{"time", "level", "msg"}
+	values := []string{time.Now().Format(time.RFC3339), level.String(), msg}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		value := "MISSING"
+		if i+1 < len(keysAndValues) {
+			value = formatLogValue(keysAndValues[i+1])
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	var line string
+	if l.json {
+		line = jsonLine(keys, values)
+	} else {
+		line = logfmtLine(keys, values)
+	}
+	for _, secret := range l.secrets {
+		line = strings.ReplaceAll(line, secret, redacted)
+	}
+	io.WriteString(l.out, line+"\n")
+}
+
+func formatLogValue(value interface{}) string {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func logfmtLine(keys []string, values []string) string {
+	pairs := make([]string, len(keys))
+	for i := range keys {
+		value := values[i]
+		if value == "" || strings.ContainsAny(value, " =\"\n\t") {
+			value = strconv.Quote(value)
+		}
+		pairs[i] = keys[i] + "=" + value
+	}
+	return strings.Join(pairs, " ")
+}
+
+func jsonLine(keys []string, values []string) string {
+	pairs := make([]string, len(keys))
+	for i := range keys {
+		key, _ := json.Marshal(keys[i])
+		value, _ := json.Marshal(values[i])
+		pairs[i] = string(key) + ":" + string(value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// telegramLogger passes the log of the Telegram bot API package into the structured log.
+// The package only uses Printf for the payloads that it logs in debug mode
+type telegramLogger struct{}
+
+func (telegramLogger) Println(v ...interface{}) {
+	logWarn(strings.TrimSpace(fmt.Sprintln(v...)), "source", "telegram-bot-api")
+}
+
+func (telegramLogger) Printf(format string, v ...interface{}) {
+	logDebug(strings.TrimSpace(fmt.Sprintf(format, v...)), "source", "telegram-bot-api")
+}
+
+// stdLogWriter passes anything written with the standard log package into the structured log
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	logInfo(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// captureLog configures the logger to write to the returned buffer until restore is called
+func captureLog(level logLevel, format string, secrets ...string) (buffer *bytes.Buffer, restore func()) {
+	buffer = &bytes.Buffer{}
+	out := logger.out
+	logger.out = buffer
+	configureLogging(level, format, secrets...)
+	return buffer, func() {
+		logger.out = out
+		configureLogging(logLevelInfo, "logfmt")
+	}
+}
+
+func TestLogSkipsEntriesBelowTheLevel(t *testing.T) {
+	buffer, restore := captureLog(logLevelInfo, "logfmt")
+	defer restore()
+
+	logDebug("Handled update", "text", "my home address")
+	logInfo("Stored job", "job_id", 1)
+	if strings.Contains(buffer.String(), "my home address") {
+		t.Errorf("Expected debug entry to be skipped: %s", buffer)
+	}
+	if !strings.Contains(buffer.String(), "level=info msg=\"Stored job\" job_id=1") {
+		t.Errorf("Expected info entry: %s", buffer)
+	}
+}
+
+func TestLogfmtQuotesValues(t *testing.T) {
+	buffer, restore := captureLog(logLevelDebug, "logfmt")
+	defer restore()
+
+	logWarn("Unable to send message", "chat_id", int64(42), "err", errors.New(`bad "request"`), "empty", "")
+	line := buffer.String()
+	for _, field := range []string{`chat_id=42`, `err="bad \"request\""`, `empty=""`, `level=warn`} {
+		if !strings.Contains(line, field) {
+			t.Errorf("Expected %s in: %s", field, line)
+		}
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	buffer, restore := captureLog(logLevelInfo, "json")
+	defer restore()
+
+	logError("Unable to schedule job", "job_id", 7, "odd")
+	entry := map[string]string{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line but got %s: %v", buffer, err)
+	}
+	if entry["level"] != "error" || entry["msg"] != "Unable to schedule job" || entry["job_id"] != "7" || entry["odd"] != "MISSING" {
+		t.Errorf("Unexpected entry: %v", entry)
+	}
+}
+
+func TestLogRedactsSecrets(t *testing.T) {
+	buffer, restore := captureLog(logLevelDebug, "logfmt", "123:secret-token", "")
+	defer restore()
+
+	logWarn("Unable to send message", "err", errors.New("Post https://api.telegram.org/bot123:secret-token/sendMessage: timeout"))
+	telegramLogger{}.Printf("Endpoint: %s, response: %s", "getMe", "123:secret-token")
+	if strings.Contains(buffer.String(), "secret-token") {
+		t.Errorf("Expected the token to be redacted: %s", buffer)
+	}
+	if strings.Count(buffer.String(), redacted) != 2 {
+		t.Errorf("Expected two redactions: %s", buffer)
+	}
+}
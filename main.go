@@ -3,115 +3,334 @@ package main
 import (
 	"bufio"
 	"bus-notifier/refdata"
-	"log"
+	"flag"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
-	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
 )
 
-const jobDBFile string = "job.db"
-const userStateDBFile string = "user_state.db"
-
-var outgoingMessages chan tgbotapi.Chattable
+var outgoingMessages *messageQueue
 var outgoingCallbackResponses chan tgbotapi.CallbackConfig
+var incomingInlineQueries chan *tgbotapi.InlineQuery
+var backgroundReplies chan func() registrationReply
+var answerSenders sync.WaitGroup
 var incomingMessages tgbotapi.UpdatesChannel
 var bot *tgbotapi.BotAPI
+var webhookServer *http.Server
+var monitoringServer *http.Server
 var cronner *cron.Cron
 var refreshCronEntryID cron.EntryID
+var backupCronner *cron.Cron
 var busServiceLookUp map[string]bool
 var refDataDB refdata.DB
-var storedJobDB JobDB
-var userStateDB UserStateDB
+var store *Store
+var storedJobDB JobStore
+var userStateDB UserStateStore
+var preferencesDB PreferencesStore
 
 func initTelegramAPI() {
-	botToken := os.Getenv("TELEGRAM_API_TOKEN")
-	newBot, err := tgbotapi.NewBotAPI(botToken)
+	// Successful getUpdates are recorded for the readiness check
+	client := &http.Client{Transport: updatesTracker{http.DefaultTransport}}
+	newBot, err := tgbotapi.NewBotAPIWithClient(config.TelegramAPIToken, client)
 	if err != nil {
-		log.Fatalln(err)
+		logFatal("Unable to connect to Telegram", "err", err)
 	}
 	bot = newBot
-	bot.Debug = true
-	log.Printf("Authorized on account %s", bot.Self.UserName)
+	// Raw payloads contain users' messages, so they are only logged at debug level
+	bot.Debug = config.LogLevel == logLevelDebug
+	logInfo("Authorized", "account", bot.Self.UserName)
+	// Telegram has just answered, so updates are not considered stale until updatesStaleAfter from now
+	recordUpdatesSuccess()
+
+	// Updates are received by long polling unless webhook mode is configured
+	if config.UpdateMode == "webhook" {
+		incomingMessages, webhookServer, err = listenForWebhook(bot,
+			config.WebhookListenAddress,
+			config.WebhookURL,
+			config.WebhookSecretToken)
+		if err != nil {
+			logFatal("Unable to listen for webhook", "err", err)
+		}
+		return
+	}
 
+	// Webhook has to be removed for getUpdates to work, in case the bot was in webhook mode before
+	if _, err := bot.RemoveWebhook(); err != nil {
+		logFatal("Unable to remove webhook", "err", err)
+	}
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	incomingMessages, err = bot.GetUpdatesChan(u)
 	if err != nil {
-		log.Fatalln(err)
+		logFatal("Unable to get updates", "err", err)
 	}
 }
 
 func initRefData() {
-	busServiceLookUp = make(map[string]bool)
+	var err error
+	busServiceLookUp, err = readBusServices(config.BusServicesFile)
+	if err != nil {
+		logFatal("Unable to read bus services", "path", config.BusServicesFile, "err", err)
+	}
 
-	file, err := os.Open("refdata/bus_services.txt")
+	refDataDB, err = refdata.OpenRefDataDB(config.RefDataDBFile)
 	if err != nil {
-		log.Fatalln(err)
+		logFatal("Unable to open reference data db", "path", config.RefDataDBFile, "err", err)
+	}
+}
+
+// readBusServices reads the bus services file, which has one bus service number per line
+func readBusServices(busServicesFile string) (map[string]bool, error) {
+	busServices := make(map[string]bool)
+
+	file, err := os.Open(busServicesFile)
+	if err != nil {
+		return nil, err
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		busServiceLookUp[scanner.Text()] = true
-	}
-	if err := scanner.Err(); err != nil {
-		log.Fatalln(err)
+		if scanner.Text() != "" {
+			busServices[scanner.Text()] = true
+		}
 	}
-
-	refDataDB = refdata.NewRefDataDB("refdata/refdata.db")
+	return busServices, scanner.Err()
 }
 
 func initOutgoingChannels() {
-	outgoingMessages = make(chan tgbotapi.Chattable)
-	outgoingCallbackResponses = make(chan tgbotapi.CallbackConfig)
+	outgoingMessages = newMessageQueue(alarmQueueSize, replyQueueSize)
+	outgoingCallbackResponses = make(chan tgbotapi.CallbackConfig, replyQueueSize)
+	incomingInlineQueries = make(chan *tgbotapi.InlineQuery, replyQueueSize)
+	backgroundReplies = make(chan func() registrationReply, replyQueueSize)
 }
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalln(err)
+	cmd, args := findCommand(os.Args[1:])
+	if cmd == nil {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(newCommandFlagSet(cmd), args); err != nil {
+		logFatal(err.Error(), "command", cmd.name)
+	}
+}
+
+// serve runs the bot until it receives SIGINT or SIGTERM
+func serve(flagSet *flag.FlagSet, args []string) error {
+	if err := loadConfig(flagSet, args); err != nil {
+		return err
+	}
+	if err := config.ValidateForServe(); err != nil {
+		return err
 	}
 
 	initTelegramAPI()
 	initRefData()
 	initOutgoingChannels()
 
-	storedJobDB = NewJobDB(jobDBFile)
-	userStateDB = NewUserStateDB(userStateDBFile)
+	// In-memory storage is for local development, nothing is kept after the bot stops
+	var err error
+	if config.StorageBackend == "memory" {
+		store = NewMemoryStore()
+	} else {
+		store, err = OpenStore(config.JobDBFile, config.UserStateDBFile, config.PreferencesDBFile)
+		if err != nil {
+			return err
+		}
+	}
+	defer store.Close()
+	defer refDataDB.Close()
+
+	storedJobDB = store.Jobs
+	userStateDB = store.UserStates
+	preferencesDB = store.Preferences
+	initBackups()
+	if config.MonitoringListenAddress != "" {
+		monitoringServer = startMonitoringServer(config.MonitoringListenAddress)
+	}
 
 	// bootstrapJobsForTesting()
 	go func() {
-		for outgoingMessage := range outgoingMessages {
-			bot.Send(outgoingMessage)
+		for {
+			message, priority := outgoingMessages.Pop()
+			err := observeTelegramRequest("send", func() error {
+				_, err := bot.Send(message)
+				return err
+			})
+			if err != nil {
+				logWarn("Unable to send message", "chat_id", chatIDOf(message), "priority", priority, "err", err)
+				todayStats.TelegramError()
+			}
+			if _, ok := message.(arrivalNotification); ok {
+				notificationsSent.WithLabelValues(notificationResult(err)).Inc()
+			}
+			outgoingMessages.Done()
 		}
 	}()
+	answerSenders.Add(3)
 	go func() {
+		defer answerSenders.Done()
 		for outgoingCallbackResponse := range outgoingCallbackResponses {
-			bot.AnswerCallbackQuery(outgoingCallbackResponse)
+			answerCallbackQuery(outgoingCallbackResponse)
+		}
+	}()
+	// Replies that wait on DataMall or Telegram are made apart from the update loop, so that they do not hold up other updates
+	go func() {
+		defer answerSenders.Done()
+		for background := range backgroundReplies {
+			reply := background()
+			if reply.replyMessage != nil {
+				outgoingMessages.Push(priorityReply, reply.replyMessage)
+			}
+			// The callback responses channel may already be closed, so the callback is answered from here
+			if reply.callbackResponse != (tgbotapi.CallbackConfig{}) {
+				answerCallbackQuery(reply.callbackResponse)
+			}
+		}
+	}()
+	// Inline queries are answered apart from the update loop, as each one waits for DataMall
+	go func() {
+		defer answerSenders.Done()
+		for inlineQuery := range incomingInlineQueries {
+			outgoingInlineAnswer := handleInlineQuery(inlineQuery)
+			err := observeTelegramRequest("answerInlineQuery", func() error {
+				_, err := bot.AnswerInlineQuery(outgoingInlineAnswer)
+				return err
+			})
+			if err != nil {
+				logWarn("Unable to answer inline query", "err", err)
+				todayStats.TelegramError()
+			}
 		}
 	}()
 
-	go handleStoredJobs()
-	handleIncomingMessages()
+	handleStoredJobs()
+
+	stopUpdates := make(chan struct{})
+	updatesStopped := make(chan struct{})
+	go func() {
+		handleIncomingMessages(stopUpdates)
+		close(updatesStopped)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	logInfo("Shutting down", "signal", <-signals)
+	shutdown(stopUpdates, updatesStopped)
+	return nil
 }
 
-func handleIncomingMessages() {
-	for update := range incomingMessages {
-		if update.Message == nil && update.CallbackQuery == nil {
+// initBackups schedules backups of the databases, which are kept in the configured backup directory.
+// Only the newest configured number of backups are kept
+func initBackups() {
+	if config.StorageBackend == "memory" {
+		return
+	}
+
+	// Kept apart from cronner, which removes all of its jobs at midnight
+	backupCronner = cron.New(cron.WithLocation(location))
+	_, err := backupCronner.AddFunc(config.BackupSchedule, func() {
+		backupAndPrune(store, config.BackupDir, config.BackupRetain)
+	})
+	if err != nil {
+		logFatal("Invalid backup schedule", "err", err)
+	}
+	backupCronner.Start()
+}
+
+// answerCallbackQuery answers the callback query, logging the error if it could not be answered
+func answerCallbackQuery(callbackResponse tgbotapi.CallbackConfig) {
+	err := observeTelegramRequest("answerCallbackQuery", func() error {
+		_, err := bot.AnswerCallbackQuery(callbackResponse)
+		return err
+	})
+	if err != nil {
+		logWarn("Unable to answer callback query", "err", err)
+		todayStats.TelegramError()
+	}
+}
+
+// handleIncomingMessages handles updates one at a time, until stop is closed.
+// Callback responses, inline queries and background replies are only sent from here, so their channels are closed when it returns
+func handleIncomingMessages(stop <-chan struct{}) {
+	defer close(outgoingCallbackResponses)
+	defer close(incomingInlineQueries)
+	defer close(backgroundReplies)
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-stop:
+			return
+		case update = <-incomingMessages:
+		}
+
+		if update.Message == nil && update.CallbackQuery == nil && update.InlineQuery == nil {
 			continue
 		}
+		countUpdate(update)
 
-		registrationReply := handleRegistration(update)
+		if update.InlineQuery != nil {
+			// Telegram sends an inline query as the user types, so the older queries are dropped rather than holding up other updates
+			select {
+			case incomingInlineQueries <- update.InlineQuery:
+			default:
+				logDebug("Dropped inline query", "update_id", update.UpdateID)
+			}
+			continue
+		}
+
+		start := time.Now()
+		reply := routeUpdate(update)
+		logDebug("Handled update", "update_id", update.UpdateID, "latency", time.Since(start))
 
-		if registrationReply.replyMessage != nil {
-			outgoingMessages <- registrationReply.replyMessage
+		if reply.replyMessage != nil {
+			outgoingMessages.Push(priorityReply, reply.replyMessage)
 		}
 
 		zero := tgbotapi.CallbackConfig{}
-		if registrationReply.callbackResponse != zero {
-			outgoingCallbackResponses <- registrationReply.callbackResponse
+		if reply.callbackResponse != zero {
+			outgoingCallbackResponses <- reply.callbackResponse
+		}
+
+		if reply.background != nil {
+			backgroundReplies <- reply.background
 		}
 	}
 }
+
+// routeUpdate passes the update to its handler, updates from groups are first checked by the group handler
+func routeUpdate(update tgbotapi.Update) registrationReply {
+	rememberLanguageCode(update)
+	switch {
+	case isMigrationUpdate(update):
+		return handleMigration(update)
+	case isGroupUpdate(update):
+		return handleGroupUpdate(update)
+	default:
+		return handleUpdate(update)
+	}
+}
+
+// handleUpdate passes the update to its handler, only updates that are
+// not meant for any other handler go through the registration process
+func handleUpdate(update tgbotapi.Update) registrationReply {
+	switch {
+	case isAdminUpdate(update):
+		return handleAdminCommand(update)
+	case isNotificationCallback(update):
+		return handleNotificationCallback(update)
+	case isSettingsUpdate(update):
+		return handleSettings(update)
+	case isImportUpdate(update):
+		return handleImport(update)
+	default:
+		return handleRegistration(update)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryJobDB is a JobStore that keeps registered bus alarm jobs in memory
+type MemoryJobDB struct {
+	mutex  sync.RWMutex
+	lastID uint64
+	jobs   map[uint64]BusInfoJob
+}
+
+// NewMemoryJobDB returns an empty MemoryJobDB
+func NewMemoryJobDB() *MemoryJobDB {
+	return &MemoryJobDB{jobs: make(map[uint64]BusInfoJob)}
+}
+
+// StoreJob assigns an ID to the registered bus alarm and stores it
+func (s *MemoryJobDB) StoreJob(newBusInfoJob BusInfoJob) (BusInfoJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastID++
+	newBusInfoJob.ID = s.lastID
+	newBusInfoJob.Weekdays = append([]time.Weekday{}, newBusInfoJob.Weekdays...)
+	s.jobs[newBusInfoJob.ID] = newBusInfoJob
+	logInfo("Stored job", "job_id", newBusInfoJob.ID, "chat_id", newBusInfoJob.ChatID)
+	return newBusInfoJob, nil
+}
+
+// getJobs returns the bus alarms that match, in the order that they were stored
+func (s *MemoryJobDB) getJobs(match func(BusInfoJob) bool) []BusInfoJob {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	jobs := []BusInfoJob{}
+	for _, job := range s.jobs {
+		if match(job) {
+			job.Weekdays = append([]time.Weekday{}, job.Weekdays...)
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// GetJobsByDay retrieves all bus alarms for the particular given day
+func (s *MemoryJobDB) GetJobsByDay(weekday time.Weekday) ([]BusInfoJob, error) {
+	return s.getJobs(func(job BusInfoJob) bool {
+		return job.HasWeekday(weekday)
+	}), nil
+}
+
+// GetJobsByChatID retrieves all bus alarms registered by a user identified by a ChatID
+func (s *MemoryJobDB) GetJobsByChatID(chatID int64) ([]BusInfoJob, error) {
+	return s.getJobs(func(job BusInfoJob) bool {
+		return job.ChatID == chatID
+	}), nil
+}
+
+// GetAllJobs retrieves every registered bus alarm
+func (s *MemoryJobDB) GetAllJobs() ([]BusInfoJob, error) {
+	return s.getJobs(func(job BusInfoJob) bool {
+		return true
+	}), nil
+}
+
+// DeleteJob deletes the bus alarm with the given ID
+func (s *MemoryJobDB) DeleteJob(jobID uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.jobs, jobID)
+	return nil
+}
+
+// UpdateJob replaces the bus alarm that has the same ID
+func (s *MemoryJobDB) UpdateJob(busInfoJob BusInfoJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[busInfoJob.ID]; !ok {
+		return fmt.Errorf("Alarm %d does not exist", busInfoJob.ID)
+	}
+	busInfoJob.Weekdays = append([]time.Weekday{}, busInfoJob.Weekdays...)
+	s.jobs[busInfoJob.ID] = busInfoJob
+	return nil
+}
+
+// MoveJobs moves the bus alarms of a chat to another chat, keeping their IDs
+func (s *MemoryJobDB) MoveJobs(fromChatID int64, toChatID int64) ([]BusInfoJob, error) {
+	movedJobs, _ := s.GetJobsByChatID(fromChatID)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range movedJobs {
+		movedJobs[i].ChatID = toChatID
+		s.jobs[movedJobs[i].ID] = movedJobs[i]
+	}
+	return movedJobs, nil
+}
+
+// MemoryUserStateDB is a UserStateStore that keeps user states in memory
+type MemoryUserStateDB struct {
+	mutex  sync.RWMutex
+	states map[int64]UserState
+}
+
+// NewMemoryUserStateDB returns an empty MemoryUserStateDB
+func NewMemoryUserStateDB() *MemoryUserStateDB {
+	return &MemoryUserStateDB{states: make(map[int64]UserState)}
+}
+
+// GetUserState retrieves the stored user state, nil if there is none
+func (s *MemoryUserStateDB) GetUserState(chatID int64) (*UserState, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	storedUserState, ok := s.states[chatID]
+	if !ok {
+		return nil, nil
+	}
+	userState := copyUserState(storedUserState)
+	return &userState, nil
+}
+
+// SaveUserState saves the user state
+func (s *MemoryUserStateDB) SaveUserState(chatID int64, userState UserState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	userState.ChatID = chatID
+	s.states[chatID] = copyUserState(userState)
+	return nil
+}
+
+// DeleteUserState deletes the saved user state
+func (s *MemoryUserStateDB) DeleteUserState(chatID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.states, chatID)
+	return nil
+}
+
+// SelectedDays is a map, so it has to be copied for the stored user state not to be changed by the caller
+func copyUserState(userState UserState) UserState {
+	selectedDays := make(map[time.Weekday]bool)
+	for day, selected := range userState.SelectedDays {
+		selectedDays[day] = selected
+	}
+	userState.SelectedDays = selectedDays
+	return userState
+}
+
+// MemoryPreferencesDB is a PreferencesStore that keeps user preferences in memory
+type MemoryPreferencesDB struct {
+	mutex       sync.RWMutex
+	preferences map[int64]Preferences
+}
+
+// NewMemoryPreferencesDB returns an empty MemoryPreferencesDB
+func NewMemoryPreferencesDB() *MemoryPreferencesDB {
+	return &MemoryPreferencesDB{preferences: make(map[int64]Preferences)}
+}
+
+// GetPreferences retrieves the stored preferences, returning the default preferences if there are none
+func (s *MemoryPreferencesDB) GetPreferences(chatID int64) (Preferences, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.preferences[chatID], nil
+}
+
+// SavePreferences saves the user's preferences
+func (s *MemoryPreferencesDB) SavePreferences(chatID int64, preferences Preferences) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.preferences[chatID] = preferences
+	return nil
+}
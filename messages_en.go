@@ -0,0 +1,107 @@
+package main
+
+var messagesEN = map[string]string{
+	"language.name": "English",
+
+	"weekday.0":      "Sunday",
+	"weekday.1":      "Monday",
+	"weekday.2":      "Tuesday",
+	"weekday.3":      "Wednesday",
+	"weekday.4":      "Thursday",
+	"weekday.5":      "Friday",
+	"weekday.6":      "Saturday",
+	"weekdayShort.0": "Sun",
+	"weekdayShort.1": "Mon",
+	"weekdayShort.2": "Tues",
+	"weekdayShort.3": "Wed",
+	"weekdayShort.4": "Thur",
+	"weekdayShort.5": "Fri",
+	"weekdayShort.6": "Sat",
+	"listSeparator":  ", ",
+
+	"exitHint":           "Stop me with /exit",
+	"okay":               "Okay!",
+	"dontUnderstand":     "I don't understand.",
+	"somethingWentWrong": "Something went wrong, please try again later.",
+	"start":              "Start by sending me /register or if you want to delete an alarm, send me /delete\n\nChange how alarms look with /settings, or move your alarms to another account with /export & /import\n\nChange my language with /language",
+	"noAlarms":           "You have no registered alarms",
+	"alarm":              "%d. %s - %s - Bus %s @ %s",
+
+	"delete.ask":     "Which alarm do you want to delete? Tell me the number!",
+	"delete.invalid": "Invalid selection",
+
+	"register.askBus":              "Which bus would you like to be alerted for?",
+	"register.invalidBus":          "Invalid bus, please try again",
+	"register.askBusStop":          "Which bus stop do you want to be alerted for? Tell me the bus stop code.\n\nYou can look for the bus stop code at %s",
+	"register.invalidBusStop":      "This bus stop is not serviced by the bus %s, please try again.\n\nYou can look for the bus stop code at %s",
+	"register.askDays":             "Which days?",
+	"register.selectedDays":        "Selected: %s",
+	"register.noDays":              "None",
+	"register.done":                "Done",
+	"register.selectAtLeastOneDay": "Select at least one day",
+	"register.askTime":             "What time? Pick it below, or type it, e.g. 07:30, 7.30pm or 1930",
+	"register.hours":               "« Hours",
+	"register.invalidTime":         "Invalid time specified. Try 07:30, 7.30pm or 1930 please.",
+	"register.confirmTime":         "Did you mean %s or %s?",
+	"register.registered":          "You will be reminded for bus %s at %s (%s) every %s %s",
+	"register.duplicate":           "You already have this alarm every %s, so I didn't add it again",
+	"register.alreadyCovered":      "(%s already covered by your existing alarm)",
+
+	"arrival.title":        "Bus %s @ %s",
+	"arrival.arriving":     "Arr",
+	"arrival.unknown":      "No estimate",
+	"arrival.minutes":      "%.0f mins",
+	"arrival.doubleDecker": "double-decker",
+	"arrival.bendy":        "bendy",
+	"arrival.nextBus":      "Next bus: %s",
+	"arrival.secondBus":    "2nd bus: %s",
+	"arrival.thirdBus":     "3rd bus: %s",
+
+	"notification.refresh":         "Refresh",
+	"notification.snooze":          "Snooze 5 min",
+	"notification.snoozed":         "I'll remind you again in 5 minutes, unless I restart before then",
+	"notification.unableToRefresh": "Unable to get the bus arrivals, please try again later",
+	"notification.unableToFetch":   "Unable to get the arrival timings of bus %s @ %s right now",
+
+	"settings.title":          "How should I show bus arrivals?",
+	"settings.layout":         "Layout: %s",
+	"settings.time":           "Time: %s",
+	"settings.description":    "Bus stop description: %s",
+	"settings.managers":       "Who can manage this group's alarms: %s",
+	"settings.managersButton": "Manage alarms: %s",
+	"settings.tapToChange":    "Tap on a setting to change it",
+	"settings.verbose":        "Verbose",
+	"settings.compact":        "Compact",
+	"settings.clockTime":      "Clock time",
+	"settings.minutesFromNow": "Minutes from now",
+	"settings.hidden":         "Hidden",
+	"settings.shown":          "Shown",
+	"settings.adminsOnly":     "Admins only",
+	"settings.everyone":       "Everyone",
+
+	"language.ask":       "Which language should I use?\n\nNow: %s",
+	"language.automatic": "Automatic, from your Telegram app",
+
+	"export.caption":    "Your %d alarms. Send this file to me from another account to set them up there",
+	"import.ask":        "Send me the file from /export, and I'll show you the alarms in it before setting them up",
+	"import.askInGroup": "Reply to this message with the file from /export, and I'll show you the alarms in it before setting them up",
+	"import.tooLarge":   "This file is too large to be an export of your alarms",
+	"import.notExport":  "This is not a file from /export: %s",
+	"import.tooMany":    "This file has %d alarms, I can only import %d at a time",
+	"import.noneValid":  "None of the alarms in this file can be set up",
+	"import.preview":    "I'll set up these %d alarms:",
+	"import.skipping":   "Skipping these alarms from the file:",
+	"import.noBus":      "bus %s does not exist",
+	"import.noBusStop":  "bus stop %s is not serviced by the bus %s",
+	"import.confirm":    "Import %d alarms",
+	"import.cancel":     "Cancel",
+	"import.cancelled":  "Okay, I didn't import anything",
+	"import.imported":   "Imported %d alarms, see them with /delete",
+	"import.duplicates": "Skipped %d alarms that you already have",
+	"import.notWaiting": "This import is no longer waiting, send me the file again",
+
+	"group.inProgress":       "%s is in the middle of registering an alarm, only they or a group admin can stop it with /exit",
+	"group.adminsOnly":       "Only the group's admins can manage alarms in this group",
+	"group.adminsOnlyButton": "Only the group's admins can change this",
+	"group.onlyInitiator":    "Only %s can answer this",
+}
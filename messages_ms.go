@@ -0,0 +1,107 @@
+package main
+
+var messagesMS = map[string]string{
+	"language.name": "Bahasa Melayu",
+
+	"weekday.0":      "Ahad",
+	"weekday.1":      "Isnin",
+	"weekday.2":      "Selasa",
+	"weekday.3":      "Rabu",
+	"weekday.4":      "Khamis",
+	"weekday.5":      "Jumaat",
+	"weekday.6":      "Sabtu",
+	"weekdayShort.0": "Ahd",
+	"weekdayShort.1": "Isn",
+	"weekdayShort.2": "Sel",
+	"weekdayShort.3": "Rab",
+	"weekdayShort.4": "Kha",
+	"weekdayShort.5": "Jum",
+	"weekdayShort.6": "Sab",
+	"listSeparator":  ", ",
+
+	"exitHint":           "Hentikan saya dengan /exit",
+	"okay":               "Baiklah!",
+	"dontUnderstand":     "Saya tidak faham.",
+	"somethingWentWrong": "Ada masalah, sila cuba lagi nanti.",
+	"start":              "Mulakan dengan menghantar /register, atau /delete untuk memadam penggera\n\nUbah paparan penggera dengan /settings, atau pindahkan penggera anda ke akaun lain dengan /export & /import\n\nTukar bahasa dengan /language",
+	"noAlarms":           "Anda tiada penggera berdaftar",
+	"alarm":              "%d. %s - %s - Bas %s @ %s",
+
+	"delete.ask":     "Penggera mana yang anda mahu padam? Beritahu saya nombornya!",
+	"delete.invalid": "Pilihan tidak sah",
+
+	"register.askBus":              "Bas mana yang anda mahu diingatkan?",
+	"register.invalidBus":          "Bas tidak sah, sila cuba lagi",
+	"register.askBusStop":          "Perhentian bas mana yang anda mahu diingatkan? Beritahu saya kod perhentian bas.\n\nAnda boleh mencari kod perhentian bas di %s",
+	"register.invalidBusStop":      "Bas %s tidak berhenti di perhentian bas ini, sila cuba lagi.\n\nAnda boleh mencari kod perhentian bas di %s",
+	"register.askDays":             "Hari apa?",
+	"register.selectedDays":        "Dipilih: %s",
+	"register.noDays":              "Tiada",
+	"register.done":                "Selesai",
+	"register.selectAtLeastOneDay": "Pilih sekurang-kurangnya satu hari",
+	"register.askTime":             "Pukul berapa? Pilih di bawah, atau taip, contohnya 07:30, 7.30pm atau 1930",
+	"register.hours":               "« Jam",
+	"register.invalidTime":         "Masa tidak sah. Sila cuba 07:30, 7.30pm atau 1930.",
+	"register.confirmTime":         "Adakah anda maksudkan %s atau %s?",
+	"register.registered":          "Anda akan diingatkan tentang bas %s di %s (%s) setiap %s %s",
+	"register.duplicate":           "Anda sudah ada penggera ini setiap %s, jadi saya tidak menambahnya lagi",
+	"register.alreadyCovered":      "(%s sudah diliputi oleh penggera sedia ada anda)",
+
+	"arrival.title":        "Bas %s @ %s",
+	"arrival.arriving":     "Tiba",
+	"arrival.unknown":      "Tiada anggaran",
+	"arrival.minutes":      "%.0f min",
+	"arrival.doubleDecker": "dua tingkat",
+	"arrival.bendy":        "bas sendeng",
+	"arrival.nextBus":      "Bas seterusnya: %s",
+	"arrival.secondBus":    "Bas ke-2: %s",
+	"arrival.thirdBus":     "Bas ke-3: %s",
+
+	"notification.refresh":         "Muat semula",
+	"notification.snooze":          "Tunda 5 min",
+	"notification.snoozed":         "Saya akan ingatkan anda lagi dalam 5 minit, kecuali jika saya dimulakan semula sebelum itu",
+	"notification.unableToRefresh": "Tidak dapat mendapatkan ketibaan bas, sila cuba lagi nanti",
+	"notification.unableToFetch":   "Tidak dapat mendapatkan masa ketibaan bas %s @ %s sekarang",
+
+	"settings.title":          "Bagaimana saya patut memaparkan ketibaan bas?",
+	"settings.layout":         "Susun atur: %s",
+	"settings.time":           "Masa: %s",
+	"settings.description":    "Keterangan perhentian bas: %s",
+	"settings.managers":       "Siapa boleh mengurus penggera kumpulan ini: %s",
+	"settings.managersButton": "Urus penggera: %s",
+	"settings.tapToChange":    "Ketik tetapan untuk mengubahnya",
+	"settings.verbose":        "Terperinci",
+	"settings.compact":        "Ringkas",
+	"settings.clockTime":      "Waktu jam",
+	"settings.minutesFromNow": "Minit dari sekarang",
+	"settings.hidden":         "Disembunyikan",
+	"settings.shown":          "Dipaparkan",
+	"settings.adminsOnly":     "Pentadbir sahaja",
+	"settings.everyone":       "Semua orang",
+
+	"language.ask":       "Bahasa apa yang patut saya gunakan?\n\nSekarang: %s",
+	"language.automatic": "Automatik, daripada aplikasi Telegram anda",
+
+	"export.caption":    "%d penggera anda. Hantar fail ini kepada saya dari akaun lain untuk menyediakannya di sana",
+	"import.ask":        "Hantar fail daripada /export kepada saya, dan saya akan tunjukkan penggera di dalamnya sebelum menyediakannya",
+	"import.askInGroup": "Balas mesej ini dengan fail daripada /export, dan saya akan tunjukkan penggera di dalamnya sebelum menyediakannya",
+	"import.tooLarge":   "Fail ini terlalu besar untuk menjadi eksport penggera anda",
+	"import.notExport":  "Ini bukan fail daripada /export: %s",
+	"import.tooMany":    "Fail ini mempunyai %d penggera, saya hanya boleh mengimport %d pada satu masa",
+	"import.noneValid":  "Tiada penggera dalam fail ini yang boleh disediakan",
+	"import.preview":    "Saya akan menyediakan %d penggera ini:",
+	"import.skipping":   "Melangkau penggera ini daripada fail:",
+	"import.noBus":      "bas %s tidak wujud",
+	"import.noBusStop":  "bas %[2]s tidak berhenti di perhentian bas %[1]s",
+	"import.confirm":    "Import %d penggera",
+	"import.cancel":     "Batal",
+	"import.cancelled":  "Baiklah, saya tidak mengimport apa-apa",
+	"import.imported":   "%d penggera diimport, lihat dengan /delete",
+	"import.duplicates": "%d penggera yang anda sudah ada telah dilangkau",
+	"import.notWaiting": "Import ini tidak lagi menunggu, hantar fail itu sekali lagi",
+
+	"group.inProgress":       "%s sedang mendaftarkan penggera, hanya mereka atau admin kumpulan boleh menghentikannya dengan /exit",
+	"group.adminsOnly":       "Hanya pentadbir kumpulan boleh mengurus penggera dalam kumpulan ini",
+	"group.adminsOnlyButton": "Hanya pentadbir kumpulan boleh mengubah ini",
+	"group.onlyInitiator":    "Hanya %s boleh menjawab ini",
+}
@@ -0,0 +1,107 @@
+package main
+
+var messagesTA = map[string]string{
+	"language.name": "தமிழ்",
+
+	"weekday.0":      "ஞாயிறு",
+	"weekday.1":      "திங்கள்",
+	"weekday.2":      "செவ்வாய்",
+	"weekday.3":      "புதன்",
+	"weekday.4":      "வியாழன்",
+	"weekday.5":      "வெள்ளி",
+	"weekday.6":      "சனி",
+	"weekdayShort.0": "ஞா",
+	"weekdayShort.1": "தி",
+	"weekdayShort.2": "செ",
+	"weekdayShort.3": "பு",
+	"weekdayShort.4": "வி",
+	"weekdayShort.5": "வெ",
+	"weekdayShort.6": "ச",
+	"listSeparator":  ", ",
+
+	"exitHint":           "நிறுத்த /exit அனுப்புங்கள்",
+	"okay":               "சரி!",
+	"dontUnderstand":     "எனக்குப் புரியவில்லை.",
+	"somethingWentWrong": "ஏதோ தவறு நடந்தது, பிறகு மீண்டும் முயலுங்கள்.",
+	"start":              "/register அனுப்பித் தொடங்குங்கள், நினைவூட்டலை நீக்க /delete அனுப்புங்கள்\n\nநினைவூட்டல்கள் தோன்றும் விதத்தை /settings மூலம் மாற்றுங்கள், அல்லது /export & /import மூலம் உங்கள் நினைவூட்டல்களை வேறு கணக்கிற்கு மாற்றுங்கள்\n\nமொழியை /language மூலம் மாற்றுங்கள்",
+	"noAlarms":           "உங்களிடம் பதிவுசெய்த நினைவூட்டல்கள் இல்லை",
+	"alarm":              "%d. %s - %s - பேருந்து %s @ %s",
+
+	"delete.ask":     "எந்த நினைவூட்டலை நீக்க வேண்டும்? அதன் எண்ணைச் சொல்லுங்கள்!",
+	"delete.invalid": "தவறான தேர்வு",
+
+	"register.askBus":              "எந்தப் பேருந்துக்கு நினைவூட்ட வேண்டும்?",
+	"register.invalidBus":          "தவறான பேருந்து, மீண்டும் முயலுங்கள்",
+	"register.askBusStop":          "எந்தப் பேருந்து நிறுத்தத்திற்கு நினைவூட்ட வேண்டும்? நிறுத்தக் குறியீட்டைச் சொல்லுங்கள்.\n\nநிறுத்தக் குறியீட்டை %s இல் தேடலாம்",
+	"register.invalidBusStop":      "பேருந்து %s இந்த நிறுத்தத்தில் நிற்பதில்லை, மீண்டும் முயலுங்கள்.\n\nநிறுத்தக் குறியீட்டை %s இல் தேடலாம்",
+	"register.askDays":             "எந்த நாட்கள்?",
+	"register.selectedDays":        "தேர்ந்தெடுத்தவை: %s",
+	"register.noDays":              "எதுவும் இல்லை",
+	"register.done":                "முடிந்தது",
+	"register.selectAtLeastOneDay": "குறைந்தது ஒரு நாளைத் தேர்ந்தெடுங்கள்",
+	"register.askTime":             "எத்தனை மணிக்கு? கீழே தேர்ந்தெடுங்கள், அல்லது தட்டச்சு செய்யுங்கள், எ.கா. 07:30, 7.30pm அல்லது 1930",
+	"register.hours":               "« மணி",
+	"register.invalidTime":         "தவறான நேரம். 07:30, 7.30pm அல்லது 1930 போன்று தாருங்கள்.",
+	"register.confirmTime":         "%s அல்லது %s, எதைக் குறிப்பிடுகிறீர்கள்?",
+	"register.registered":          "பேருந்து %s, %s (%s) நிறுத்தத்திற்கு ஒவ்வொரு %s %s மணிக்கு நினைவூட்டப்படும்",
+	"register.duplicate":           "ஒவ்வொரு %s இந்த நினைவூட்டல் ஏற்கனவே உள்ளது, எனவே மீண்டும் சேர்க்கவில்லை",
+	"register.alreadyCovered":      "(%s ஏற்கனவே உள்ள நினைவூட்டலில் அடங்கும்)",
+
+	"arrival.title":        "பேருந்து %s @ %s",
+	"arrival.arriving":     "வருகிறது",
+	"arrival.unknown":      "மதிப்பீடு இல்லை",
+	"arrival.minutes":      "%.0f நிமி",
+	"arrival.doubleDecker": "இரட்டை அடுக்கு",
+	"arrival.bendy":        "இணைப்புப் பேருந்து",
+	"arrival.nextBus":      "அடுத்த பேருந்து: %s",
+	"arrival.secondBus":    "2வது பேருந்து: %s",
+	"arrival.thirdBus":     "3வது பேருந்து: %s",
+
+	"notification.refresh":         "புதுப்பி",
+	"notification.snooze":          "5 நிமி கழித்து",
+	"notification.snoozed":         "5 நிமிடங்களில் மீண்டும் நினைவூட்டுகிறேன், அதற்குள் நான் மறுதொடக்கம் செய்யப்படாவிட்டால்",
+	"notification.unableToRefresh": "பேருந்து வருகை நேரத்தைப் பெற முடியவில்லை, பிறகு மீண்டும் முயலுங்கள்",
+	"notification.unableToFetch":   "பேருந்து %s @ %s வருகை நேரத்தை இப்போது பெற முடியவில்லை",
+
+	"settings.title":          "பேருந்து வருகையை எப்படிக் காட்ட வேண்டும்?",
+	"settings.layout":         "தளவமைப்பு: %s",
+	"settings.time":           "நேரம்: %s",
+	"settings.description":    "நிறுத்த விவரம்: %s",
+	"settings.managers":       "இந்தக் குழுவின் நினைவூட்டல்களை நிர்வகிப்பவர்கள்: %s",
+	"settings.managersButton": "நினைவூட்டல்களை நிர்வகித்தல்: %s",
+	"settings.tapToChange":    "மாற்ற ஒரு அமைப்பைத் தட்டுங்கள்",
+	"settings.verbose":        "விரிவானது",
+	"settings.compact":        "சுருக்கமானது",
+	"settings.clockTime":      "கடிகார நேரம்",
+	"settings.minutesFromNow": "இன்னும் எத்தனை நிமிடங்கள்",
+	"settings.hidden":         "மறைக்கப்பட்டது",
+	"settings.shown":          "காட்டப்படுகிறது",
+	"settings.adminsOnly":     "நிர்வாகிகள் மட்டும்",
+	"settings.everyone":       "அனைவரும்",
+
+	"language.ask":       "எந்த மொழியைப் பயன்படுத்த வேண்டும்?\n\nதற்போது: %s",
+	"language.automatic": "தானியங்கி, உங்கள் Telegram செயலியிலிருந்து",
+
+	"export.caption":    "உங்கள் %d நினைவூட்டல்கள். வேறு கணக்கில் அமைக்க, அந்தக் கணக்கிலிருந்து இந்தக் கோப்பை எனக்கு அனுப்புங்கள்",
+	"import.ask":        "/export கோப்பை எனக்கு அனுப்புங்கள், அதிலுள்ள நினைவூட்டல்களை அமைக்கும் முன் காட்டுகிறேன்",
+	"import.askInGroup": "/export கோப்புடன் இந்தச் செய்திக்குப் பதிலளியுங்கள், அதிலுள்ள நினைவூட்டல்களை அமைக்கும் முன் காட்டுகிறேன்",
+	"import.tooLarge":   "இந்தக் கோப்பு உங்கள் நினைவூட்டல்களின் ஏற்றுமதியாக இருக்க மிகப் பெரியது",
+	"import.notExport":  "இது /export கோப்பு அல்ல: %s",
+	"import.tooMany":    "இந்தக் கோப்பில் %d நினைவூட்டல்கள் உள்ளன, ஒரே நேரத்தில் %d மட்டுமே இறக்க முடியும்",
+	"import.noneValid":  "இந்தக் கோப்பிலுள்ள எந்த நினைவூட்டலையும் அமைக்க முடியாது",
+	"import.preview":    "இந்த %d நினைவூட்டல்களை அமைக்கிறேன்:",
+	"import.skipping":   "கோப்பிலுள்ள இந்த நினைவூட்டல்கள் தவிர்க்கப்படுகின்றன:",
+	"import.noBus":      "பேருந்து %s இல்லை",
+	"import.noBusStop":  "நிறுத்தம் %s இல் பேருந்து %s நிற்பதில்லை",
+	"import.confirm":    "%d நினைவூட்டல்களை இறக்கு",
+	"import.cancel":     "ரத்துசெய்",
+	"import.cancelled":  "சரி, எதையும் இறக்கவில்லை",
+	"import.imported":   "%d நினைவூட்டல்கள் இறக்கப்பட்டன, /delete மூலம் பாருங்கள்",
+	"import.duplicates": "ஏற்கனவே உள்ள %d நினைவூட்டல்கள் தவிர்க்கப்பட்டன",
+	"import.notWaiting": "இந்த இறக்கம் இனி காத்திருக்கவில்லை, கோப்பை மீண்டும் அனுப்புங்கள்",
+
+	"group.inProgress":       "%s நினைவூட்டலைப் பதிவுசெய்து கொண்டிருக்கிறார், அவர் அல்லது குழு நிர்வாகி மட்டுமே /exit மூலம் நிறுத்த முடியும்",
+	"group.adminsOnly":       "இந்தக் குழுவில் குழு நிர்வாகிகள் மட்டுமே நினைவூட்டல்களை நிர்வகிக்க முடியும்",
+	"group.adminsOnlyButton": "குழு நிர்வாகிகள் மட்டுமே இதை மாற்ற முடியும்",
+	"group.onlyInitiator":    "%s மட்டுமே இதற்குப் பதிலளிக்க முடியும்",
+}
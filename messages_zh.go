@@ -0,0 +1,107 @@
+package main
+
+var messagesZH = map[string]string{
+	"language.name": "简体中文",
+
+	"weekday.0":      "星期日",
+	"weekday.1":      "星期一",
+	"weekday.2":      "星期二",
+	"weekday.3":      "星期三",
+	"weekday.4":      "星期四",
+	"weekday.5":      "星期五",
+	"weekday.6":      "星期六",
+	"weekdayShort.0": "日",
+	"weekdayShort.1": "一",
+	"weekdayShort.2": "二",
+	"weekdayShort.3": "三",
+	"weekdayShort.4": "四",
+	"weekdayShort.5": "五",
+	"weekdayShort.6": "六",
+	"listSeparator":  "、",
+
+	"exitHint":           "发送 /exit 取消",
+	"okay":               "好的！",
+	"dontUnderstand":     "我不明白。",
+	"somethingWentWrong": "出了点问题，请稍后再试。",
+	"start":              "发送 /register 开始设置提醒，或发送 /delete 删除提醒\n\n用 /settings 更改提醒的显示方式，或用 /export 和 /import 把提醒转移到另一个账号\n\n用 /language 更改语言",
+	"noAlarms":           "您没有设置任何提醒",
+	"alarm":              "%d. %s - %s - %s 路巴士 @ %s",
+
+	"delete.ask":     "您要删除哪个提醒？请告诉我编号！",
+	"delete.invalid": "选择无效",
+
+	"register.askBus":              "您想设置哪一路巴士的提醒？",
+	"register.invalidBus":          "巴士路线无效，请重试",
+	"register.askBusStop":          "您想设置哪个车站的提醒？请告诉我车站编号。\n\n您可以在 %s 查找车站编号",
+	"register.invalidBusStop":      "%s 路巴士不经过这个车站，请重试。\n\n您可以在 %s 查找车站编号",
+	"register.askDays":             "哪几天？",
+	"register.selectedDays":        "已选择：%s",
+	"register.noDays":              "无",
+	"register.done":                "完成",
+	"register.selectAtLeastOneDay": "请至少选择一天",
+	"register.askTime":             "几点？请在下方选择，或直接输入，例如 07:30、7.30pm 或 1930",
+	"register.hours":               "« 小时",
+	"register.invalidTime":         "时间无效，请输入例如 07:30、7.30pm 或 1930 的时间。",
+	"register.confirmTime":         "您是指 %s 还是 %s？",
+	"register.registered":          "我会在每%[4]s %[5]s提醒您 %[1]s 路巴士到达 %[2]s（%[3]s）的时间",
+	"register.duplicate":           "您已经有每%s的这个提醒，所以没有重复添加",
+	"register.alreadyCovered":      "（%s已包含在您现有的提醒中）",
+
+	"arrival.title":        "%s 路巴士 @ %s",
+	"arrival.arriving":     "到站",
+	"arrival.unknown":      "暂无预计时间",
+	"arrival.minutes":      "%.0f 分钟",
+	"arrival.doubleDecker": "双层巴士",
+	"arrival.bendy":        "铰接巴士",
+	"arrival.nextBus":      "下一班：%s",
+	"arrival.secondBus":    "第二班：%s",
+	"arrival.thirdBus":     "第三班：%s",
+
+	"notification.refresh":         "刷新",
+	"notification.snooze":          "5 分钟后再提醒",
+	"notification.snoozed":         "我会在 5 分钟后再提醒您，除非我在此之前重启",
+	"notification.unableToRefresh": "无法获取巴士到站时间，请稍后再试",
+	"notification.unableToFetch":   "现在无法获取 %s 路巴士 @ %s 的到站时间",
+
+	"settings.title":          "巴士到站信息要怎么显示？",
+	"settings.layout":         "版式：%s",
+	"settings.time":           "时间：%s",
+	"settings.description":    "车站名称：%s",
+	"settings.managers":       "谁可以管理这个群组的提醒：%s",
+	"settings.managersButton": "管理提醒：%s",
+	"settings.tapToChange":    "点击设置即可更改",
+	"settings.verbose":        "详细",
+	"settings.compact":        "简洁",
+	"settings.clockTime":      "钟点时间",
+	"settings.minutesFromNow": "还有几分钟",
+	"settings.hidden":         "隐藏",
+	"settings.shown":          "显示",
+	"settings.adminsOnly":     "仅限管理员",
+	"settings.everyone":       "所有人",
+
+	"language.ask":       "我应该使用哪种语言？\n\n目前：%s",
+	"language.automatic": "自动，跟随您的 Telegram 应用",
+
+	"export.caption":    "您的 %d 个提醒。从另一个账号把这个文件发给我，就能在那里设置这些提醒",
+	"import.ask":        "把 /export 的文件发给我，我会先列出里面的提醒，再帮您设置",
+	"import.askInGroup": "用 /export 的文件回复这条消息，我会先列出里面的提醒，再帮您设置",
+	"import.tooLarge":   "这个文件太大了，不是您的提醒导出文件",
+	"import.notExport":  "这不是 /export 的文件：%s",
+	"import.tooMany":    "这个文件有 %d 个提醒，我一次只能导入 %d 个",
+	"import.noneValid":  "这个文件里的提醒都无法设置",
+	"import.preview":    "我会设置这 %d 个提醒：",
+	"import.skipping":   "跳过文件里的这些提醒：",
+	"import.noBus":      "%s 路巴士不存在",
+	"import.noBusStop":  "车站 %s 没有 %s 路巴士经过",
+	"import.confirm":    "导入 %d 个提醒",
+	"import.cancel":     "取消",
+	"import.cancelled":  "好的，我没有导入任何提醒",
+	"import.imported":   "已导入 %d 个提醒，用 /delete 查看",
+	"import.duplicates": "已跳过 %d 个您已有的提醒",
+	"import.notWaiting": "这次导入已失效，请重新发送文件",
+
+	"group.inProgress":       "%s 正在设置提醒，只有他们或群管理员可以用 /exit 取消",
+	"group.adminsOnly":       "这个群组只有管理员可以管理提醒",
+	"group.adminsOnlyButton": "只有群组管理员可以更改这项设置",
+	"group.onlyInitiator":    "只有 %s 可以回答",
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace string = "bus_notifier"
+
+var (
+	updatesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "updates_processed_total",
+		Help:      "Updates processed, by command and by the registration state that the user was in.",
+	}, []string{"command", "state"})
+
+	alarmsFired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "alarms_fired_total",
+		Help:      "Scheduled bus alarms that went off.",
+	})
+
+	notificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "notifications_total",
+		Help:      "Bus arrival notifications sent to Telegram, by whether they were delivered or failed, including those whose arrival timings could not be fetched.",
+	}, []string{"result"})
+
+	datamallRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "datamall_request_duration_seconds",
+		Help:      "Latency of requests to LTA DataMall.",
+	}, []string{"endpoint"})
+
+	datamallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "datamall_errors_total",
+		Help:      "Failed requests to LTA DataMall.",
+	}, []string{"endpoint"})
+
+	telegramRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "telegram_request_duration_seconds",
+		Help:      "Latency of requests to the Telegram bot API.",
+	}, []string{"method"})
+
+	telegramErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "telegram_errors_total",
+		Help:      "Failed requests to the Telegram bot API.",
+	}, []string{"method"})
+
+	telegramRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "telegram_rate_limited_total",
+		Help:      "Requests to the Telegram bot API that were rejected with 429 Too Many Requests.",
+	}, []string{"method"})
+)
+
+func init() {
+	for _, priority := range []messagePriority{priorityAlarm, priorityReply} {
+		priority := priority
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "outgoing_queue_depth",
+			Help:        "Messages waiting in the outgoing queue.",
+			ConstLabels: prometheus.Labels{"priority": priority.String()},
+		}, func() float64 {
+			if outgoingMessages == nil {
+				return 0
+			}
+			return float64(outgoingMessages.Depth(priority))
+		})
+	}
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cron_entries",
+		Help:      "Entries scheduled in today's cron, including the midnight refresh.",
+	}, func() float64 {
+		if cronner == nil {
+			return 0
+		}
+		return float64(len(cronner.Entries()))
+	})
+}
+
+// Commands that are counted by name, any other command is counted as other_command so that users cannot add labels
+var knownCommands = map[string]bool{
+	"start": true, "register": true, "delete": true, "exit": true, "settings": true, "language": true, "export": true, "import": true,
+	"stats": true, "broadcast": true, "jobs": true, "user": true,
+}
+
+// countUpdate counts the update by command, and by the registration state that the user is in before it is handled
+func countUpdate(update tgbotapi.Update) {
+	var command string
+	var chatID int64
+	switch {
+	case update.InlineQuery != nil:
+		updatesProcessed.WithLabelValues("inline_query", "none").Inc()
+		logInfo("Update", "update_id", update.UpdateID, "command", "inline_query")
+		return
+	case update.CallbackQuery != nil:
+		command = "callback"
+		chatID = update.CallbackQuery.Message.Chat.ID
+	case update.Message.IsCommand() && knownCommands[update.Message.Command()]:
+		command = update.Message.Command()
+		chatID = update.Message.Chat.ID
+	case update.Message.IsCommand():
+		command = "other_command"
+		chatID = update.Message.Chat.ID
+	default:
+		command = "message"
+		chatID = update.Message.Chat.ID
+	}
+
+	state := "none"
+	if userState, err := userStateDB.GetUserState(chatID); err == nil && userState != nil {
+		state = strconv.Itoa(userState.State)
+	}
+	updatesProcessed.WithLabelValues(command, state).Inc()
+	logInfo("Update", "update_id", update.UpdateID, "chat_id", chatID, "command", command, "state", state)
+}
+
+// notificationResult is the result label of a notification that was sent with the given error
+func notificationResult(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "delivered"
+}
+
+// observeDatamallRequest times the request to LTA DataMall, and counts it if it failed
+func observeDatamallRequest(endpoint string, request func() error) error {
+	start := time.Now()
+	err := request()
+	latency := time.Since(start)
+	datamallRequestDuration.WithLabelValues(endpoint).Observe(latency.Seconds())
+	logDebug("LTA DataMall request", "endpoint", endpoint, "latency", latency, "err", err)
+	if err != nil {
+		datamallErrors.WithLabelValues(endpoint).Inc()
+	}
+	return err
+}
+
+// observeTelegramRequest times the request to the Telegram bot API, and counts it if it failed or was rate limited
+func observeTelegramRequest(method string, request func() error) error {
+	start := time.Now()
+	err := request()
+	latency := time.Since(start)
+	telegramRequestDuration.WithLabelValues(method).Observe(latency.Seconds())
+	logDebug("Telegram request", "method", method, "latency", latency, "err", err)
+	if err != nil {
+		telegramErrors.WithLabelValues(method).Inc()
+	}
+	if telegramErr, ok := err.(tgbotapi.Error); ok && telegramErr.RetryAfter > 0 {
+		telegramRateLimited.WithLabelValues(method).Inc()
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCountUpdateByCommandAndState(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	userStateDB = memoryStore.UserStates
+	defer func() { userStateDB = nil }()
+	userStateDB.SaveUserState(12345, UserState{State: 4, SelectedDays: make(map[time.Weekday]bool)})
+
+	before := testutil.ToFloat64(updatesProcessed.WithLabelValues("message", "4"))
+	countUpdate(tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 12345}, Text: "07:30"}})
+	if after := testutil.ToFloat64(updatesProcessed.WithLabelValues("message", "4")); after != before+1 {
+		t.Errorf("Expected message in state 4 to be counted")
+	}
+
+	before = testutil.ToFloat64(updatesProcessed.WithLabelValues("other_command", "none"))
+	countUpdate(commandUpdate(54321, "/whatever"))
+	if after := testutil.ToFloat64(updatesProcessed.WithLabelValues("other_command", "none")); after != before+1 {
+		t.Errorf("Expected unknown command to be counted as other_command")
+	}
+}
+
+func TestObserveTelegramRequestCountsRateLimits(t *testing.T) {
+	rateLimitedBefore := testutil.ToFloat64(telegramRateLimited.WithLabelValues("test"))
+	errorsBefore := testutil.ToFloat64(telegramErrors.WithLabelValues("test"))
+
+	observeTelegramRequest("test", func() error {
+		return tgbotapi.Error{Message: "Too Many Requests: retry after 5", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}
+	})
+	observeTelegramRequest("test", func() error {
+		return errors.New("Bad Request: chat not found")
+	})
+	observeTelegramRequest("test", func() error {
+		return nil
+	})
+
+	if testutil.ToFloat64(telegramRateLimited.WithLabelValues("test")) != rateLimitedBefore+1 {
+		t.Errorf("Expected one rate limited request")
+	}
+	if testutil.ToFloat64(telegramErrors.WithLabelValues("test")) != errorsBefore+2 {
+		t.Errorf("Expected two failed requests")
+	}
+}
+
+func TestUnfetchedNotificationIsCountedAsFailed(t *testing.T) {
+	preferencesDB = NewMemoryStore().Preferences
+	config = &Config{LTAAPIToken: "token"}
+	outgoingMessages = newMessageQueue(1, 1)
+	defaultTransport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = unreachableServer{}
+	defer func() {
+		preferencesDB, config, outgoingMessages = nil, nil, nil
+		http.DefaultClient.Transport = defaultTransport
+	}()
+
+	before := testutil.ToFloat64(notificationsSent.WithLabelValues("failed"))
+	fetchAndPushInfo(BusInfoJob{ID: 1, ChatID: 2, BusStopCode: "43411", BusServiceNo: "506"})
+	if after := testutil.ToFloat64(notificationsSent.WithLabelValues("failed")); after != before+1 {
+		t.Errorf("Expected a notification without arrival timings to be counted as failed")
+	}
+	// The send loop only counts arrival notifications, so the fallback is not counted again once delivered
+	message, priority := outgoingMessages.Pop()
+	if _, ok := message.(arrivalNotification); ok || priority != priorityAlarm {
+		t.Errorf("Expected the fallback at alarm priority, apart from arrival notifications, but got %T at %s", message, priority)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// Every database keeps the version of its schema in this bucket
+const schemaBucket string = "schema"
+const schemaVersionKey string = "version"
+
+// errDryRun rolls back the transaction that migrations were made in
+var errDryRun = errors.New("Dry run")
+
+// migration changes the schema of a database from version-1 to version
+type migration struct {
+	version     int
+	description string
+	// migrate makes the changes in tx, returning a description of each change made
+	migrate func(tx *bolt.Tx) ([]string, error)
+}
+
+// migrationReport describes the changes made by a migration
+type migrationReport struct {
+	version     int
+	description string
+	changes     []string
+}
+
+func getSchemaVersion(tx *bolt.Tx) (int, error) {
+	b := tx.Bucket([]byte(schemaBucket))
+	if b == nil {
+		return 0, nil
+	}
+	v := b.Get([]byte(schemaVersionKey))
+	if v == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("Unable to decode schema version: %v", err)
+	}
+	return version, nil
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(schemaBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+// runMigrations makes the migrations newer than the schema version of the database, in order,
+// within a single transaction. With dryRun, the transaction is rolled back after the changes are reported
+func runMigrations(db *bolt.DB, migrations []migration, dryRun bool) ([]migrationReport, error) {
+	reports := []migrationReport{}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		currentVersion, err := getSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		latestVersion := len(migrations)
+		if currentVersion > latestVersion {
+			return fmt.Errorf("%s has schema version %d, which is newer than the latest known version %d", db.Path(), currentVersion, latestVersion)
+		}
+
+		for i, m := range migrations {
+			if m.version != i+1 {
+				return fmt.Errorf("Migration %q has version %d but should be version %d", m.description, m.version, i+1)
+			}
+			if m.version <= currentVersion {
+				continue
+			}
+
+			changes, err := m.migrate(tx)
+			if err != nil {
+				return fmt.Errorf("Migration %d (%s) of %s failed: %v", m.version, m.description, db.Path(), err)
+			}
+			if err := setSchemaVersion(tx, m.version); err != nil {
+				return err
+			}
+			reports = append(reports, migrationReport{version: m.version, description: m.description, changes: changes})
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err == errDryRun {
+		return reports, nil
+	}
+	return reports, err
+}
+
+// createBucketMigration creates the bucket if it does not exist yet
+func createBucketMigration(tx *bolt.Tx, bucket string) ([]string, error) {
+	if tx.Bucket([]byte(bucket)) != nil {
+		return []string{}, nil
+	}
+	if _, err := tx.CreateBucket([]byte(bucket)); err != nil {
+		return nil, err
+	}
+	return []string{"Create bucket " + bucket}, nil
+}
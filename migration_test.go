@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestMigrationsRunInOrderOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ran := []int{}
+	migrations := []migration{
+		{version: 1, description: "first", migrate: func(tx *bolt.Tx) ([]string, error) {
+			ran = append(ran, 1)
+			return []string{}, nil
+		}},
+		{version: 2, description: "second", migrate: func(tx *bolt.Tx) ([]string, error) {
+			ran = append(ran, 2)
+			return []string{}, nil
+		}},
+	}
+
+	if _, err := runMigrations(db, migrations, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runMigrations(db, migrations, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Errorf("Migrations should run in order once, but ran %v", ran)
+	}
+
+	if _, err := runMigrations(db, migrations[:1], false); err == nil {
+		t.Errorf("Database with a newer schema version than known should be rejected")
+	}
+}
+
+func TestCorruptedUserStateIsReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	userStateStore := NewUserStateDB(db)
+	db.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucketIfNotExists([]byte(userStateStore.statesBucket))
+		return b.Put([]byte("12345"), []byte("{not json"))
+	})
+
+	if _, err := userStateStore.GetUserState(12345); err == nil {
+		t.Errorf("Decoding a corrupted user state should return an error")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// startMonitoringServer serves the Prometheus metrics on /metrics, and the health checks on /healthz and /readyz.
+// It is kept apart from the webhook server, so that it does not have to be exposed to the internet
+func startMonitoringServer(listenAddress string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+
+	server := &http.Server{Addr: listenAddress, Handler: mux}
+	go func() {
+		logInfo("Serving metrics and health checks", "address", listenAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logFatal("Unable to serve metrics and health checks", "err", err)
+		}
+	}()
+	return server
+}
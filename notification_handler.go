@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const notificationCallbackPrefix string = "alarm:"
+
+const (
+	notificationRefresh string = "refresh"
+	notificationSnooze  string = "snooze"
+)
+
+const snoozeDuration time.Duration = 5 * time.Minute
+
+// arrivalNotification is an alarm's message of bus arrival timings, which is counted in the notifications metric once sent
+type arrivalNotification struct {
+	tgbotapi.MessageConfig
+}
+
+// onceSchedule is a cron schedule that fires once, at the given time
+type onceSchedule struct {
+	at time.Time
+}
+
+// Next returns the time to fire, or the zero time once it has passed so that cron does not run the job again
+func (s onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.at) {
+		return s.at
+	}
+	return time.Time{}
+}
+
+// snoozeCronJob is a snoozed alarm in cronner, which is sent again once.
+// It is kept in cronner so that it is stopped with the rest of cronner on shutdown, but is not kept across restarts
+type snoozeCronJob struct {
+	BusInfoJob
+}
+
+// Run sends the arrival information of the snoozed alarm
+func (j snoozeCronJob) Run() {
+	fetchAndPushInfo(j.BusInfoJob)
+}
+
+// isNotificationCallback returns true if the update is a tap on a button attached to an alarm notification
+func isNotificationCallback(update tgbotapi.Update) bool {
+	return update.CallbackQuery != nil && strings.HasPrefix(update.CallbackQuery.Data, notificationCallbackPrefix)
+}
+
+// Callback data is in the format of alarm:<action>:<bus stop code>:<bus service no>
+func notificationCallbackData(action string, busStopCode string, busServiceNo string) string {
+	return strings.Join([]string{notificationCallbackPrefix + action, busStopCode, busServiceNo}, ":")
+}
+
+func buildNotificationKeyboard(language string, busStopCode string, busServiceNo string) *tgbotapi.InlineKeyboardMarkup {
+	var notificationKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "notification.refresh"), notificationCallbackData(notificationRefresh, busStopCode, busServiceNo)),
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "notification.snooze"), notificationCallbackData(notificationSnooze, busStopCode, busServiceNo)),
+		),
+	)
+	return &notificationKeyboard
+}
+
+// handleNotificationCallback refreshes the notification in place, or sends the alarm again after snoozeDuration
+func handleNotificationCallback(update tgbotapi.Update) registrationReply {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callBackID := update.CallbackQuery.ID
+	// Notifications belong to the chat, so they stay in the chat's language whoever taps on them
+	preferences := preferencesOrDefault(chatID)
+	language := languageOf(preferences)
+
+	data := strings.Split(strings.TrimPrefix(update.CallbackQuery.Data, notificationCallbackPrefix), ":")
+	if len(data) != 3 {
+		return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "dontUnderstand"))}
+	}
+	action, busStopCode, busServiceNo := data[0], data[1], data[2]
+
+	switch action {
+	case notificationRefresh:
+		// DataMall is called apart from the update loop, so that a slow DataMall does not hold up other updates
+		messageID := update.CallbackQuery.Message.MessageID
+		return registrationReply{background: func() registrationReply {
+			return refreshNotification(chatID, messageID, callBackID, preferences, busStopCode, busServiceNo)
+		}}
+
+	case notificationSnooze:
+		snoozedJob := BusInfoJob{ChatID: chatID, BusStopCode: busStopCode, BusServiceNo: busServiceNo}
+		logInfo("Snoozing alarm", "chat_id", chatID)
+		cronner.Schedule(onceSchedule{at: now().Add(snoozeDuration)}, snoozeCronJob{snoozedJob})
+		return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "notification.snoozed"))}
+	}
+	return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "dontUnderstand"))}
+}
+
+// refreshNotification edits the notification with the latest arrival information
+func refreshNotification(chatID int64, messageID int, callBackID string, preferences Preferences, busStopCode string, busServiceNo string) registrationReply {
+	language := languageOf(preferences)
+	busArrivalInformation, err := fetchBusArrivalInformation(busStopCode, busServiceNo)
+	if err != nil {
+		logWarn("Unable to refresh bus arrivals", "chat_id", chatID, "err", err)
+		return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "notification.unableToRefresh"))}
+	}
+	textMessage := busArrivalInformation.toMessageString(preferences)
+
+	editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, textMessage)
+	editedMessage.ReplyMarkup = buildNotificationKeyboard(language, busStopCode, busServiceNo)
+	return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/robfig/cron/v3"
+)
+
+func TestOnceScheduleFiresOnce(t *testing.T) {
+	at := time.Date(2020, 1, 1, 8, 5, 0, 0, time.UTC)
+	schedule := onceSchedule{at: at}
+	if next := schedule.Next(at.Add(-time.Minute)); next != at {
+		t.Errorf("Expected to fire at %s but got %s", at, next)
+	}
+	if next := schedule.Next(at); !next.IsZero() {
+		t.Errorf("Expected not to fire again but got %s", next)
+	}
+}
+
+func TestSnoozeIsScheduledInCronner(t *testing.T) {
+	preferencesDB = NewMemoryStore().Preferences
+	cronner = cron.New()
+	defer func() { preferencesDB, cronner = nil, nil }()
+
+	update := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		Data:    notificationCallbackData(notificationSnooze, "43411", "506"),
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 2}},
+	}}
+	reply := handleNotificationCallback(update)
+	if reply.callbackResponse.Text != translate(defaultLanguage, "notification.snoozed") {
+		t.Errorf("Unexpected callback response: %s", reply.callbackResponse.Text)
+	}
+
+	entries := cronner.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the snooze in cronner but got %v", entries)
+	}
+	snooze, ok := entries[0].Job.(snoozeCronJob)
+	if !ok || snooze.ChatID != 2 || snooze.BusStopCode != "43411" || snooze.BusServiceNo != "506" {
+		t.Errorf("Unexpected snooze: %+v", entries[0].Job)
+	}
+	schedule, ok := entries[0].Schedule.(onceSchedule)
+	if delay := time.Until(schedule.at); !ok || delay <= 4*time.Minute || delay > snoozeDuration {
+		t.Errorf("Expected the snooze in 5 minutes but got %s", delay)
+	}
+}
+
+// unreachableServer fails every HTTP request, as if DataMall or Telegram could not be reached
+type unreachableServer struct{}
+
+func (unreachableServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestRefreshIsRepliedInBackground(t *testing.T) {
+	preferencesDB = NewMemoryStore().Preferences
+	config = &Config{LTAAPIToken: "token"}
+	defaultTransport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = unreachableServer{}
+	defer func() {
+		preferencesDB, config = nil, nil
+		http.DefaultClient.Transport = defaultTransport
+	}()
+
+	update := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		Data:    notificationCallbackData(notificationRefresh, "43411", "506"),
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 2}},
+	}}
+	reply := handleNotificationCallback(update)
+	if reply.replyMessage != nil || reply.callbackResponse != (tgbotapi.CallbackConfig{}) || reply.background == nil {
+		t.Fatalf("Expected DataMall to be called apart from the update loop but got %+v", reply)
+	}
+
+	reply = reply.background()
+	if reply.replyMessage != nil || reply.callbackResponse.Text != translate(defaultLanguage, "notification.unableToRefresh") {
+		t.Errorf("Expected the refresh to fail without DataMall but got %+v", reply)
+	}
+}
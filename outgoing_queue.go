@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const alarmQueueSize int = 500
+const replyQueueSize int = 100
+
+// messagePriority decides which queue an outgoing message waits in
+type messagePriority int
+
+const (
+	// priorityAlarm is for scheduled bus arrival notifications, which are time-critical
+	priorityAlarm messagePriority = iota
+	// priorityReply is for replies to the user's messages
+	priorityReply
+)
+
+func (p messagePriority) String() string {
+	if p == priorityAlarm {
+		return "alarm"
+	}
+	return "reply"
+}
+
+// How often Drain checks whether every message has been sent
+const drainPollInterval time.Duration = 50 * time.Millisecond
+
+// messageQueue holds outgoing messages in bounded queues by priority,
+// queued alarms are always sent before queued replies
+type messageQueue struct {
+	alarms  chan tgbotapi.Chattable
+	replies chan tgbotapi.Chattable
+	// Messages that have been pushed but not marked as sent with Done
+	pending int64
+}
+
+// newMessageQueue returns a messageQueue with the given buffer size for each priority
+func newMessageQueue(alarmBufferSize int, replyBufferSize int) *messageQueue {
+	return &messageQueue{
+		alarms:  make(chan tgbotapi.Chattable, alarmBufferSize),
+		replies: make(chan tgbotapi.Chattable, replyBufferSize),
+	}
+}
+
+func (q *messageQueue) queue(priority messagePriority) chan tgbotapi.Chattable {
+	if priority == priorityAlarm {
+		return q.alarms
+	}
+	return q.replies
+}
+
+// Push adds a message to the queue of the given priority, blocking if that queue is full
+func (q *messageQueue) Push(priority messagePriority, message tgbotapi.Chattable) {
+	atomic.AddInt64(&q.pending, 1)
+	queue := q.queue(priority)
+	select {
+	case queue <- message:
+	default:
+		logWarn("Outgoing queue is full, waiting for space", "priority", priority)
+		queue <- message
+	}
+}
+
+// Pop blocks until a message is available, returning alarms ahead of replies, along with the message's priority
+func (q *messageQueue) Pop() (tgbotapi.Chattable, messagePriority) {
+	select {
+	case message := <-q.alarms:
+		return message, priorityAlarm
+	default:
+	}
+
+	select {
+	case message := <-q.alarms:
+		return message, priorityAlarm
+	case message := <-q.replies:
+		return message, priorityReply
+	}
+}
+
+// Done marks a popped message as sent
+func (q *messageQueue) Done() {
+	atomic.AddInt64(&q.pending, -1)
+}
+
+// Drain waits for every pushed message to be sent, giving up after timeout.
+// Returns false if there are still messages that have not been sent
+func (q *messageQueue) Drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&q.pending) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+	return true
+}
+
+// Depth returns the number of messages waiting in the queue of the given priority
+func (q *messageQueue) Depth(priority messagePriority) int {
+	return len(q.queue(priority))
+}
+
+// chatIDOf returns the chat that the message is sent to, for logging messages that could not be sent
+func chatIDOf(message tgbotapi.Chattable) int64 {
+	switch m := message.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case arrivalNotification:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestAlarmsArePoppedBeforeReplies(t *testing.T) {
+	queue := newMessageQueue(10, 10)
+	queue.Push(priorityReply, tgbotapi.NewMessage(1, "reply"))
+	queue.Push(priorityAlarm, tgbotapi.NewMessage(2, "alarm"))
+
+	if queue.Depth(priorityAlarm) != 1 || queue.Depth(priorityReply) != 1 {
+		t.Errorf("Queue depth should be 1 for each priority")
+	}
+
+	first, firstPriority := queue.Pop()
+	second, secondPriority := queue.Pop()
+	firstText, secondText := first.(tgbotapi.MessageConfig).Text, second.(tgbotapi.MessageConfig).Text
+	if firstText != "alarm" || secondText != "reply" {
+		t.Errorf("Alarm should be sent before reply, got %s then %s", firstText, secondText)
+	}
+	if firstPriority != priorityAlarm || secondPriority != priorityReply {
+		t.Errorf("Popped messages should keep their priority, got %s then %s", firstPriority, secondPriority)
+	}
+
+	if queue.Depth(priorityAlarm) != 0 || queue.Depth(priorityReply) != 0 {
+		t.Errorf("Queue should be empty")
+	}
+}
+
+func TestDrainWaitsForMessagesToBeSent(t *testing.T) {
+	queue := newMessageQueue(10, 10)
+	queue.Push(priorityAlarm, tgbotapi.NewMessage(1, "alarm"))
+
+	if queue.Drain(10 * time.Millisecond) {
+		t.Errorf("Drain should give up while a message has not been sent")
+	}
+
+	go func() {
+		queue.Pop()
+		queue.Done()
+	}()
+	if !queue.Drain(time.Second) {
+		t.Errorf("Drain should return once every message has been sent")
+	}
+}
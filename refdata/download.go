@@ -0,0 +1,116 @@
+package refdata
+
+import (
+	"log"
+	"sort"
+
+	"github.com/yi-jiayu/datamall/v3"
+)
+
+// Number of records that LTA DataMall returns per page
+const pageSize int = 500
+
+// Download fetches from LTA DataMall
+// 1) Bus stops that each bus services
+// 2) Road name of each bus stop
+// 3) Every bus service number
+// for storing into the reference data db and the bus services file
+func Download(ltaToken string) ([]BusRoute, []BusStop, []string, error) {
+	apiClient := datamall.NewDefaultClient(ltaToken)
+
+	log.Println("Downloading from LTA API...")
+	rawBusRoutes, err := downloadAllBusRoutes(apiClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Println("Number of downloaded bus routes:", len(rawBusRoutes))
+
+	rawBusStops, err := downloadAllBusStops(apiClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Println("Number of downloaded bus stops:", len(rawBusStops))
+
+	log.Println("Processing data...")
+	return processBusRoutes(rawBusRoutes, rawBusStops), processBusStops(rawBusStops), processBusServices(rawBusRoutes), nil
+}
+
+// Both download functions can be generalised
+func downloadAllBusRoutes(apiClient datamall.APIClient) ([]datamall.BusRoute, error) {
+	allBusRoutes := []datamall.BusRoute{}
+
+	offset := 0
+	for {
+		log.Println("offset:", offset)
+		response, err := apiClient.GetBusRoutes(offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Value) == 0 {
+			return allBusRoutes, nil
+		}
+		allBusRoutes = append(allBusRoutes, response.Value...)
+		offset += pageSize
+	}
+}
+
+func processBusRoutes(rawBusRoutes []datamall.BusRoute, rawBusStops []datamall.BusStop) []BusRoute {
+	var processedBusRoutes []BusRoute
+
+	busStopCodeToDesc := make(map[string]string)
+	for _, busStop := range rawBusStops {
+		busStopCodeToDesc[busStop.BusStopCode] = busStop.Description
+	}
+
+	for _, busRoute := range rawBusRoutes {
+		busStop := BusStop{BusStopCode: busRoute.BusStopCode, Description: busStopCodeToDesc[busRoute.BusStopCode]}
+		processedBusRoute := BusRoute{BusServiceNo: busRoute.ServiceNo, Direction: busRoute.Direction, BusStop: busStop, StopSequence: busRoute.StopSequence}
+		processedBusRoutes = append(processedBusRoutes, processedBusRoute)
+	}
+
+	return processedBusRoutes
+}
+
+func downloadAllBusStops(apiClient datamall.APIClient) ([]datamall.BusStop, error) {
+	allBusStops := []datamall.BusStop{}
+
+	offset := 0
+	for {
+		log.Println("offset:", offset)
+		response, err := apiClient.GetBusStops(offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Value) == 0 {
+			return allBusStops, nil
+		}
+		allBusStops = append(allBusStops, response.Value...)
+		offset += pageSize
+	}
+}
+
+func processBusStops(rawBusStops []datamall.BusStop) []BusStop {
+
+	var processedBusStops []BusStop
+
+	for _, busStop := range rawBusStops {
+		busStop := BusStop{BusStopCode: busStop.BusStopCode, Description: busStop.Description}
+		processedBusStops = append(processedBusStops, busStop)
+	}
+
+	return processedBusStops
+}
+
+// processBusServices returns the sorted bus service numbers that have a route
+func processBusServices(rawBusRoutes []datamall.BusRoute) []string {
+	seen := make(map[string]bool)
+	busServiceNos := []string{}
+	for _, busRoute := range rawBusRoutes {
+		if !seen[busRoute.ServiceNo] {
+			seen[busRoute.ServiceNo] = true
+			busServiceNos = append(busServiceNos, busRoute.ServiceNo)
+		}
+	}
+	sort.Strings(busServiceNos)
+	return busServiceNos
+}
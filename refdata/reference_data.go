@@ -2,7 +2,7 @@ package refdata
 
 import (
 	"encoding/json"
-	"log"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -23,30 +23,33 @@ type BusStop struct {
 
 // DB contains the operations to store store/retrieve reference data
 type DB struct {
-	dbFile         string
+	db             *bolt.DB
 	busRouteBucket string
 	busStopBucket  string
 }
 
-// NewRefDataDB returns an initialised instance of the reference data db
-func NewRefDataDB(dbFile string) DB {
-	return DB{dbFile: dbFile, busRouteBucket: "routes", busStopBucket: "busstops"}
+// OpenRefDataDB opens the reference data db, which should be closed with Close when no longer needed
+func OpenRefDataDB(dbFile string) (DB, error) {
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return DB{}, err
+	}
+	return DB{db: db, busRouteBucket: "routes", busStopBucket: "busstops"}, nil
+}
+
+// Close closes the reference data db
+func (refDataDB *DB) Close() error {
+	return refDataDB.db.Close()
 }
 
 // StoreBusRoutes saves bus routes information into the referece data db
-func (refDataDB *DB) StoreBusRoutes(busRoutes []BusRoute) {
+func (refDataDB *DB) StoreBusRoutes(busRoutes []BusRoute) error {
 	busToBusRoutes := make(map[string][]BusRoute)
 	for _, busRoute := range busRoutes {
 		busToBusRoutes[busRoute.BusServiceNo] = append(busToBusRoutes[busRoute.BusServiceNo], busRoute)
 	}
 
-	db, err := bolt.Open(refDataDB.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.Update(func(tx *bolt.Tx) error {
+	return refDataDB.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(refDataDB.busRouteBucket))
 		if err != nil {
 			return err
@@ -60,7 +63,9 @@ func (refDataDB *DB) StoreBusRoutes(busRoutes []BusRoute) {
 				return err
 			}
 
-			b.Put(key, value)
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -68,14 +73,8 @@ func (refDataDB *DB) StoreBusRoutes(busRoutes []BusRoute) {
 }
 
 // StoreBusStops saves bus stop information into the referece data db
-func (refDataDB *DB) StoreBusStops(busStops []BusStop) {
-	db, err := bolt.Open(refDataDB.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.Update(func(tx *bolt.Tx) error {
+func (refDataDB *DB) StoreBusStops(busStops []BusStop) error {
+	return refDataDB.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(refDataDB.busStopBucket))
 		if err != nil {
 			return err
@@ -89,25 +88,20 @@ func (refDataDB *DB) StoreBusStops(busStops []BusStop) {
 				return err
 			}
 
-			b.Put(key, value)
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
-
 }
 
 // GetBusRoutesByBusService retrieves the routes (both directions) of a bus service
 func (refDataDB *DB) GetBusRoutesByBusService(busServiceNo string) []BusRoute {
 	var busRoutes []BusRoute
 
-	db, err := bolt.Open(refDataDB.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.View(func(tx *bolt.Tx) error {
+	refDataDB.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(refDataDB.busRouteBucket))
 		if b == nil {
 			return nil
@@ -125,13 +119,7 @@ func (refDataDB *DB) GetBusRoutesByBusService(busServiceNo string) []BusRoute {
 func (refDataDB *DB) GetBusStopByBusStopCode(busStopCode string) BusStop {
 	var busStops BusStop
 
-	db, err := bolt.Open(refDataDB.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.View(func(tx *bolt.Tx) error {
+	refDataDB.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(refDataDB.busStopBucket))
 		if b == nil {
 			return nil
@@ -144,3 +132,49 @@ func (refDataDB *DB) GetBusStopByBusStopCode(busStopCode string) BusStop {
 	})
 	return busStops
 }
+
+// GetBusServiceNos retrieves the numbers of every bus service that has a route
+func (refDataDB *DB) GetBusServiceNos() ([]string, error) {
+	busServiceNos := []string{}
+
+	err := refDataDB.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(refDataDB.busRouteBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(key []byte, _ []byte) error {
+			busServiceNos = append(busServiceNos, string(key))
+			return nil
+		})
+	})
+	return busServiceNos, err
+}
+
+// HasBusRoutes returns true if the reference data db has the route of at least one bus service
+func (refDataDB *DB) HasBusRoutes() (bool, error) {
+	hasBusRoutes := false
+
+	err := refDataDB.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(refDataDB.busRouteBucket))
+		if b != nil {
+			key, _ := b.Cursor().First()
+			hasBusRoutes = key != nil
+		}
+		return nil
+	})
+	return hasBusRoutes, err
+}
+
+// CountBusStops returns the number of bus stops in the reference data db
+func (refDataDB *DB) CountBusStops() (int, error) {
+	count := 0
+
+	err := refDataDB.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(refDataDB.busStopBucket))
+		if b != nil {
+			count = b.Stats().KeyN
+		}
+		return nil
+	})
+	return count, err
+}
@@ -13,6 +13,8 @@ import (
 type registrationReply struct {
 	replyMessage     tgbotapi.Chattable
 	callbackResponse tgbotapi.CallbackConfig
+	// background is run apart from the update loop, for replies that wait on DataMall or Telegram
+	background func() registrationReply
 }
 
 func handleRegistration(update tgbotapi.Update) registrationReply {
@@ -24,53 +26,58 @@ func handleRegistration(update tgbotapi.Update) registrationReply {
 	}
 
 	message := update.Message
+	language := updateLanguage(update)
 
 	// Exits the registration process
 	if message != nil && message.IsCommand() && message.Command() == "exit" {
-		userStateDB.DeleteUserState(chatID)
-		reply := tgbotapi.NewMessage(chatID, "Okay!")
+		if err := userStateDB.DeleteUserState(chatID); err != nil {
+			return errorReply(chatID, err)
+		}
+		reply := tgbotapi.NewMessage(chatID, translate(language, "okay"))
 		return registrationReply{replyMessage: reply}
 	}
 
 	if message != nil && message.IsCommand() && message.Command() == "delete" {
-		storedJobs := storedJobDB.GetJobsByChatID(chatID)
+		storedJobs, err := storedJobDB.GetJobsByChatID(chatID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
 		if len(storedJobs) == 0 {
-			reply := tgbotapi.NewMessage(chatID, "You have no registered alarms")
+			reply := tgbotapi.NewMessage(chatID, translate(language, "noAlarms"))
 			return registrationReply{replyMessage: reply}
 		}
 
-		stringBuilder := strings.Builder{}
-		stringBuilder.WriteString("Which alarm do you want to delete? Tell me the number!\n")
-		for i, job := range storedJobs {
-			jobString := fmt.Sprintf("%d. %s - %s - Bus %s @ %s", i+1, job.Weekday.String(), job.ScheduledTime.ToString(), job.BusServiceNo, job.BusStopCode)
-			stringBuilder.WriteString(jobString)
-			stringBuilder.WriteString("\n")
+		userState := newUserState(5, message.From)
+		if err := userStateDB.SaveUserState(chatID, userState); err != nil {
+			return errorReply(chatID, err)
 		}
-		stringBuilder.WriteString("\nStop me with /exit")
-		userState := UserState{State: 5, SelectedDays: make(map[time.Weekday]bool)}
-		userStateDB.SaveUserState(chatID, userState)
 
-		reply := tgbotapi.NewMessage(chatID, stringBuilder.String())
+		reply := tgbotapi.NewMessage(chatID, deleteMessage(language, storedJobs))
 		return registrationReply{replyMessage: reply}
 	}
 
-	storedUserState := userStateDB.GetUserState(chatID)
+	storedUserState, err := userStateDB.GetUserState(chatID)
+	if err != nil {
+		return errorReply(chatID, err)
+	}
 
 	// If db does not have this record
 	if storedUserState == nil {
 		if message != nil && message.IsCommand() && message.Command() == "register" {
-			userState := UserState{State: 1, SelectedDays: make(map[time.Weekday]bool)}
-			userStateDB.SaveUserState(chatID, userState)
-			reply := tgbotapi.NewMessage(chatID, "Which bus would you like to be alerted for?")
+			userState := newUserState(1, message.From)
+			if err := userStateDB.SaveUserState(chatID, userState); err != nil {
+				return errorReply(chatID, err)
+			}
+			reply := tgbotapi.NewMessage(chatID, translate(language, "register.askBus"))
 			return registrationReply{replyMessage: reply}
 		}
-		reply := tgbotapi.NewMessage(chatID, "Start by sending me /register or if you want to delete an alarm, send me /delete")
+		reply := tgbotapi.NewMessage(chatID, translate(language, "start"))
 		return registrationReply{replyMessage: reply}
 	}
 
-	// Only state 3 should have nil message
-	if storedUserState.State != 3 && message == nil {
-		return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, "I don't understand.")}
+	// Only states 3 and 4 have buttons, other states should not have nil message
+	if storedUserState.State != 3 && storedUserState.State != 4 && message == nil {
+		return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(language, "dontUnderstand"))}
 	}
 
 	switch storedUserState.State {
@@ -80,15 +87,15 @@ func handleRegistration(update tgbotapi.Update) registrationReply {
 			busServiceNo := message.Text
 			storedUserState.BusServiceNo = busServiceNo
 			storedUserState.State = 2
-			userStateDB.SaveUserState(chatID, *storedUserState)
+			if err := userStateDB.SaveUserState(chatID, *storedUserState); err != nil {
+				return errorReply(chatID, err)
+			}
 
 			transitLinkURL := fmt.Sprintf("https://www.transitlink.com.sg/eservice/eguide/service_route.php?service=%s", busServiceNo)
-			message := fmt.Sprintf("Which bus stop do you want to be alerted for? Tell me the bus stop code. \n\nYou can look for the bus stop code at %s \n\nStop me with /exit", transitLinkURL)
-
-			reply := tgbotapi.NewMessage(chatID, message)
+			reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "register.askBusStop", transitLinkURL)))
 			return registrationReply{replyMessage: reply}
 		}
-		reply := tgbotapi.NewMessage(chatID, "Invalid bus, please try again \n\nStop me with /exit")
+		reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "register.invalidBus")))
 		return registrationReply{replyMessage: reply}
 
 	case 2:
@@ -97,16 +104,17 @@ func handleRegistration(update tgbotapi.Update) registrationReply {
 			if busRoute.BusStopCode == inputBusStopCode {
 				storedUserState.BusStopCode = inputBusStopCode
 				storedUserState.State = 3
-				userStateDB.SaveUserState(chatID, *storedUserState)
-				reply := tgbotapi.NewMessage(chatID, "Which days? \n\nStop me with /exit")
-				reply.ReplyMarkup = buildWeekdayKeyboard()
+				if err := userStateDB.SaveUserState(chatID, *storedUserState); err != nil {
+					return errorReply(chatID, err)
+				}
+				reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "register.askDays")))
+				reply.ReplyMarkup = buildWeekdayKeyboard(language)
 				return registrationReply{replyMessage: reply}
 			}
 		}
 		transitLinkURL := fmt.Sprintf("https://www.transitlink.com.sg/eservice/eguide/service_route.php?service=%s", storedUserState.BusServiceNo)
-
-		message := fmt.Sprintf("This bus stop is not serviced by the bus %s, please try again. \n\nYou can look for the bus stop code at %s, \n\nStop me with /exit", storedUserState.BusServiceNo, transitLinkURL)
-		reply := tgbotapi.NewMessage(chatID, message)
+		message := translate(language, "register.invalidBusStop", storedUserState.BusServiceNo, transitLinkURL)
+		reply := tgbotapi.NewMessage(chatID, withExitHint(language, message))
 		return registrationReply{replyMessage: reply}
 
 	case 3:
@@ -115,122 +123,267 @@ func handleRegistration(update tgbotapi.Update) registrationReply {
 			// If user doesn't click on Done, store day
 			if dayInt != -1 {
 				storedUserState.ToggleDay(time.Weekday(dayInt))
-				userStateDB.SaveUserState(chatID, *storedUserState)
-
-				stringBuilder := strings.Builder{}
-				stringBuilder.WriteString("Which days? \nSelected: ")
-				if len(storedUserState.GetSelectedDays()) == 0 {
-					stringBuilder.WriteString("None")
-				} else {
-					selectedDays := storedUserState.GetSelectedDays()
-					stringBuilder.WriteString(joinDaysString(selectedDays))
+				if err := userStateDB.SaveUserState(chatID, *storedUserState); err != nil {
+					return errorReply(chatID, err)
 				}
-				stringBuilder.WriteString("\n\nStop me with /exit")
+
+				selectedDays := translate(language, "register.noDays")
+				if len(storedUserState.GetSelectedDays()) > 0 {
+					selectedDays = joinDaysString(language, storedUserState.GetSelectedDays())
+				}
+				text := translate(language, "register.askDays") + "\n" + translate(language, "register.selectedDays", selectedDays)
 
 				messageID := update.CallbackQuery.Message.MessageID
-				editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, stringBuilder.String())
-				editedMessage.ReplyMarkup = buildWeekdayKeyboard()
+				editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, withExitHint(language, text))
+				editedMessage.ReplyMarkup = buildWeekdayKeyboard(language)
 
 				// Need to send CallBackConfig back, so that button stops the loading animation
 				callBackID := update.CallbackQuery.ID
 				return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
 			}
+			if len(storedUserState.GetSelectedDays()) == 0 {
+				return registrationReply{callbackResponse: tgbotapi.NewCallback(update.CallbackQuery.ID, translate(language, "register.selectAtLeastOneDay"))}
+			}
 			storedUserState.State = 4
-			userStateDB.SaveUserState(chatID, *storedUserState)
-			reply := tgbotapi.NewMessage(chatID, "What time? In the format of hh:mm \n\nStop me with /exit")
+			if err := userStateDB.SaveUserState(chatID, *storedUserState); err != nil {
+				return errorReply(chatID, err)
+			}
+			storedJobs, err := storedJobDB.GetJobsByChatID(chatID)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+			reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "register.askTime")))
+			reply.ReplyMarkup = buildHourKeyboard(recentTimes(storedJobs))
 			return registrationReply{replyMessage: reply}
 		}
 
 	case 4:
-		textArr := strings.Split(message.Text, ":")
-		hour, err := strconv.Atoi(textArr[0])
-		if err != nil || hour > 23 {
-			reply := tgbotapi.NewMessage(chatID, "Invalid time specified. In the format of hh:mm please.\n\nStop me with /exit")
+		if update.CallbackQuery != nil {
+			data := update.CallbackQuery.Data
+			messageID := update.CallbackQuery.Message.MessageID
+			callBackID := update.CallbackQuery.ID
+			// The time picker shows the minutes of the hour that the user picked, or goes back to the hours
+			if hour, err := parseTimeHourCallbackData(data); err == nil {
+				editedMessage := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, *buildMinuteKeyboard(language, hour))
+				return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+			}
+			if data == timeHoursCallback {
+				storedJobs, err := storedJobDB.GetJobsByChatID(chatID)
+				if err != nil {
+					return errorReply(chatID, err)
+				}
+				editedMessage := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, *buildHourKeyboard(recentTimes(storedJobs)))
+				return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+			}
+
+			// The user picked a time with the time picker, or one of the readings of an ambiguous time
+			scheduledTime, err := parseTimeCallbackData(data)
+			if err != nil {
+				return registrationReply{callbackResponse: tgbotapi.NewCallback(callBackID, translate(language, "dontUnderstand"))}
+			}
+			storedUserState.ScheduledTime = scheduledTime
+			text, err := registerAlarm(chatID, language, *storedUserState)
+			if err != nil {
+				return errorReply(chatID, err)
+			}
+			editedMessage := tgbotapi.NewEditMessageText(chatID, messageID, text)
+			return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+		}
+
+		readings, err := parseScheduledTime(message.Text)
+		if err != nil {
+			reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "register.invalidTime")))
 			return registrationReply{replyMessage: reply}
 		}
-		minute, err := strconv.Atoi(textArr[1])
-		if err != nil || minute > 59 {
-			reply := tgbotapi.NewMessage(chatID, "Invalid time specified. In the format of hh:mm please.\n\nStop me with /exit")
+		if len(readings) > 1 {
+			text := translate(language, "register.confirmTime", readings[0].ToString(), readings[1].ToString())
+			reply := tgbotapi.NewMessage(chatID, withExitHint(language, text))
+			reply.ReplyMarkup = buildTimeConfirmKeyboard(readings)
 			return registrationReply{replyMessage: reply}
 		}
-		storedUserState.ScheduledTime = ScheduledTime{Hour: hour, Minute: minute}
-		for _, day := range storedUserState.GetSelectedDays() {
-			dailyBusInfoJob := storedUserState.BusInfoJob
-			dailyBusInfoJob.Weekday = day
-			storedJobDB.StoreJob(dailyBusInfoJob)
-			if day == time.Now().Weekday() {
-				addJobtoCronner(cronner, dailyBusInfoJob)
-			}
+		storedUserState.ScheduledTime = readings[0]
+		text, err := registerAlarm(chatID, language, *storedUserState)
+		if err != nil {
+			return errorReply(chatID, err)
 		}
-
-		replyMessage := fmt.Sprintf("You will be reminded for bus %s at %s (%s) every %s %02d:%02d",
-			storedUserState.BusServiceNo,
-			refDataDB.GetBusStopByBusStopCode(storedUserState.BusStopCode).Description,
-			storedUserState.BusStopCode,
-			joinDaysString(storedUserState.GetSelectedDays()),
-			storedUserState.ScheduledTime.Hour,
-			storedUserState.ScheduledTime.Minute)
-		reply := tgbotapi.NewMessage(chatID, replyMessage)
+		reply := tgbotapi.NewMessage(chatID, text)
 		reply.ReplyToMessageID = message.MessageID
-		userStateDB.DeleteUserState(chatID)
 		return registrationReply{replyMessage: reply}
 
 	case 5:
 		selectedIndex, err := strconv.Atoi(message.Text)
 		indexToDelete := selectedIndex - 1
-		storedJobs := storedJobDB.GetJobsByChatID(chatID)
+		storedJobs, storeErr := storedJobDB.GetJobsByChatID(chatID)
+		if storeErr != nil {
+			return errorReply(chatID, storeErr)
+		}
 
 		if err != nil || indexToDelete < 0 || indexToDelete >= len(storedJobs) {
-			reply := tgbotapi.NewMessage(chatID, "Invalid selection\n\nStop me with /exit")
+			reply := tgbotapi.NewMessage(chatID, withExitHint(language, translate(language, "delete.invalid")))
 			return registrationReply{replyMessage: reply}
 		}
-		storedJobDB.DeleteJob(storedJobs[indexToDelete])
-
-		remainingJobs := storedJobDB.GetJobsByChatID(chatID)
-		stringBuilder := strings.Builder{}
-		stringBuilder.WriteString("Which alarm do you want to delete? Tell me the number!\n")
-		for i, job := range remainingJobs {
-			jobString := fmt.Sprintf("%d. %s - %s - Bus %s @ %s", i+1, job.Weekday.String(), job.ScheduledTime.ToString(), job.BusServiceNo, job.BusStopCode)
-			stringBuilder.WriteString(jobString)
-			stringBuilder.WriteString("\n")
+		if err := storedJobDB.DeleteJob(storedJobs[indexToDelete].ID); err != nil {
+			return errorReply(chatID, err)
 		}
-		stringBuilder.WriteString("\nStop me with /exit")
 
-		reply := tgbotapi.NewMessage(chatID, stringBuilder.String())
+		remainingJobs, err := storedJobDB.GetJobsByChatID(chatID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		reply := tgbotapi.NewMessage(chatID, deleteMessage(language, remainingJobs))
 		return registrationReply{replyMessage: reply}
 	}
-	return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, "I don't understand.")}
+	return registrationReply{replyMessage: tgbotapi.NewMessage(chatID, translate(language, "dontUnderstand"))}
 }
 
-func buildWeekdayKeyboard() *tgbotapi.InlineKeyboardMarkup {
+// registerAlarm stores and schedules the alarm that the user has finished registering, and returns the reply that describes the alarm.
+// The days are added to the same alarm on other days, and an alarm that already exists on every day is not stored again
+func registerAlarm(chatID int64, language string, userState UserState) (string, error) {
+	busInfoJob := userState.BusInfoJob
+	busInfoJob.Weekdays = userState.GetSelectedDays()
+	storedJob, coveredDays, err := storeOrMergeJob(storedJobDB, busInfoJob)
+	if err != nil {
+		return "", err
+	}
+	if err := userStateDB.DeleteUserState(chatID); err != nil {
+		return "", err
+	}
+	if len(coveredDays) == len(busInfoJob.Weekdays) {
+		return translate(language, "register.duplicate", joinDaysString(language, coveredDays)), nil
+	}
+	rescheduleJob(cronner, storedJob)
+
+	text := translate(language, "register.registered",
+		storedJob.BusServiceNo,
+		refDataDB.GetBusStopByBusStopCode(storedJob.BusStopCode).Description,
+		storedJob.BusStopCode,
+		joinDaysString(language, storedJob.Weekdays),
+		storedJob.ScheduledTime.ToString())
+	if len(coveredDays) > 0 {
+		text += "\n" + translate(language, "register.alreadyCovered", joinDaysString(language, coveredDays))
+	}
+	return text, nil
+}
+
+// newUserState returns the state of a registration that the user has just started
+func newUserState(state int, from *tgbotapi.User) UserState {
+	userState := UserState{State: state, SelectedDays: make(map[time.Weekday]bool)}
+	if from != nil {
+		userState.InitiatorID = from.ID
+		userState.InitiatorName = displayName(from)
+	}
+	return userState
+}
+
+// errorReply logs the error and lets the user know that something went wrong
+func errorReply(chatID int64, err error) registrationReply {
+	logError("Unable to handle update", "chat_id", chatID, "err", err)
+	reply := tgbotapi.NewMessage(chatID, translate(languageOf(preferencesOrDefault(chatID)), "somethingWentWrong"))
+	return registrationReply{replyMessage: reply}
+}
+
+// withExitHint adds how to stop the registration to a question in the registration
+func withExitHint(language string, text string) string {
+	return text + "\n\n" + translate(language, "exitHint")
+}
+
+// deleteMessage asks which of the alarms to delete
+func deleteMessage(language string, jobs []BusInfoJob) string {
+	stringBuilder := strings.Builder{}
+	stringBuilder.WriteString(translate(language, "delete.ask"))
+	stringBuilder.WriteString("\n")
+	for i, job := range jobs {
+		stringBuilder.WriteString(alarmString(language, i+1, job))
+		stringBuilder.WriteString("\n")
+	}
+	return withExitHint(language, stringBuilder.String())
+}
+
+// alarmString describes the alarm in one line, numbered for the user to pick from a list
+func alarmString(language string, number int, job BusInfoJob) string {
+	return translate(language, "alarm", number, joinDaysString(language, job.Weekdays), job.ScheduledTime.ToString(), job.BusServiceNo, job.BusStopCode)
+}
+
+func buildWeekdayKeyboard(language string) *tgbotapi.InlineKeyboardMarkup {
+	dayButton := func(day time.Weekday) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(shortWeekdayName(language, day), strconv.Itoa(int(day)))
+	}
 	var weekdayKeyboard = tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Mon", strconv.Itoa(int(time.Monday))),
-			tgbotapi.NewInlineKeyboardButtonData("Tues", strconv.Itoa(int(time.Tuesday))),
-			tgbotapi.NewInlineKeyboardButtonData("Wed", strconv.Itoa(int(time.Wednesday))),
-			tgbotapi.NewInlineKeyboardButtonData("Thur", strconv.Itoa(int(time.Thursday))),
-			tgbotapi.NewInlineKeyboardButtonData("Fri", strconv.Itoa(int(time.Friday))),
+			dayButton(time.Monday),
+			dayButton(time.Tuesday),
+			dayButton(time.Wednesday),
+			dayButton(time.Thursday),
+			dayButton(time.Friday),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Sat", strconv.Itoa(int(time.Saturday))),
-			tgbotapi.NewInlineKeyboardButtonData("Sun", strconv.Itoa(int(time.Sunday))),
+			dayButton(time.Saturday),
+			dayButton(time.Sunday),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Done", "-1"),
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "register.done"), "-1"),
 		),
 	)
 	return &weekdayKeyboard
 }
 
-func joinDaysString(days []time.Weekday) string {
-	stringBuilder := strings.Builder{}
-	for i, day := range days {
-		stringBuilder.WriteString(day.String())
-		if i < len(days)-1 {
-			stringBuilder.WriteString(", ")
+// buildHourKeyboard is the first step of the time picker, with a button for each hour of the day.
+// The user's recently used times are offered above the hours, so that they can be picked with one tap
+func buildHourKeyboard(recent []ScheduledTime) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(recent) > 0 {
+		rows = append(rows, timeButtonRow(recent))
+	}
+	for hour := 0; hour < 24; hour += 6 {
+		row := tgbotapi.NewInlineKeyboardRow()
+		for h := hour; h < hour+6; h++ {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%02d", h), fmt.Sprintf("%s%02d", timeHourCallbackPrefix, h)))
+		}
+		rows = append(rows, row)
+	}
+	hourKeyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &hourKeyboard
+}
+
+// buildMinuteKeyboard is the second step of the time picker, with a button for every 5 minutes of the hour
+func buildMinuteKeyboard(language string, hour int) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var times []ScheduledTime
+	for minute := 0; minute < 60; minute += timePickerMinuteStep {
+		times = append(times, ScheduledTime{Hour: hour, Minute: minute})
+		if len(times) == 4 {
+			rows = append(rows, timeButtonRow(times))
+			times = nil
 		}
 	}
-	return stringBuilder.String()
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(translate(language, "register.hours"), timeHoursCallback),
+	))
+	minuteKeyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &minuteKeyboard
+}
+
+// timeButtonRow has a button that picks each of the times
+func timeButtonRow(times []ScheduledTime) []tgbotapi.InlineKeyboardButton {
+	row := tgbotapi.NewInlineKeyboardRow()
+	for _, scheduledTime := range times {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(scheduledTime.ToString(), timeCallbackData(scheduledTime)))
+	}
+	return row
+}
+
+// buildTimeConfirmKeyboard has a button for each reading of an ambiguous time
+func buildTimeConfirmKeyboard(readings []ScheduledTime) *tgbotapi.InlineKeyboardMarkup {
+	timeKeyboard := tgbotapi.NewInlineKeyboardMarkup(timeButtonRow(readings))
+	return &timeKeyboard
+}
+
+func joinDaysString(language string, days []time.Weekday) string {
+	names := make([]string, len(days))
+	for i, day := range days {
+		names[i] = weekdayName(language, day)
+	}
+	return strings.Join(names, translate(language, "listSeparator"))
 }
 
 // splitByDireciton returns bus stops serviced by a bus in direction 1 and direction 2
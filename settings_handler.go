@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const settingsCallbackPrefix string = "settings:"
+
+const (
+	settingLayout      string = settingsCallbackPrefix + "layout"
+	settingTime        string = settingsCallbackPrefix + "time"
+	settingDescription string = settingsCallbackPrefix + "description"
+	settingAdminsOnly  string = settingsCallbackPrefix + "admins"
+	settingLanguage    string = settingsCallbackPrefix + "language:"
+)
+
+// Callback data of the language menu's button that follows the language of the user's Telegram app
+const automaticLanguage string = "auto"
+
+// isSettingsUpdate returns true if the update is the /settings or /language command, or a tap on their menus
+func isSettingsUpdate(update tgbotapi.Update) bool {
+	if update.CallbackQuery != nil {
+		return strings.HasPrefix(update.CallbackQuery.Data, settingsCallbackPrefix)
+	}
+	return update.Message != nil && update.Message.IsCommand() && (update.Message.Command() == "settings" || update.Message.Command() == "language")
+}
+
+// handleSettings shows the settings or language menu, and changes the preference that the user tapped on
+func handleSettings(update tgbotapi.Update) registrationReply {
+	if update.CallbackQuery == nil {
+		chatID := update.Message.Chat.ID
+		isGroup := isGroupChat(update.Message.Chat)
+		language := updateLanguage(update)
+		preferences, err := preferencesDB.GetPreferences(chatID)
+		if err != nil {
+			return errorReply(chatID, err)
+		}
+		if update.Message.Command() == "language" {
+			reply := tgbotapi.NewMessage(chatID, languageMessage(language, preferences))
+			reply.ReplyMarkup = buildLanguageKeyboard(language)
+			return registrationReply{replyMessage: reply}
+		}
+		reply := tgbotapi.NewMessage(chatID, settingsMessage(language, preferences, isGroup))
+		reply.ReplyMarkup = buildSettingsKeyboard(language, preferences, isGroup)
+		return registrationReply{replyMessage: reply}
+	}
+
+	chatID := update.CallbackQuery.Message.Chat.ID
+	isGroup := isGroupChat(update.CallbackQuery.Message.Chat)
+	preferences, err := preferencesDB.GetPreferences(chatID)
+	if err != nil {
+		return errorReply(chatID, err)
+	}
+	data := update.CallbackQuery.Data
+	switch {
+	case data == settingLayout:
+		preferences.Verbose = !preferences.Verbose
+	case data == settingTime:
+		preferences.ClockTime = !preferences.ClockTime
+	case data == settingDescription:
+		preferences.HideBusStopDescription = !preferences.HideBusStopDescription
+	case data == settingAdminsOnly:
+		preferences.AdminsOnly = !preferences.AdminsOnly
+	case strings.HasPrefix(data, settingLanguage):
+		preferences.Language = supportedLanguage(strings.TrimPrefix(data, settingLanguage))
+	}
+	if err := preferencesDB.SavePreferences(chatID, preferences); err != nil {
+		return errorReply(chatID, err)
+	}
+
+	// The menu is shown in the language that was just chosen
+	language := updateLanguage(update)
+	messageID := update.CallbackQuery.Message.MessageID
+	var editedMessage tgbotapi.EditMessageTextConfig
+	if strings.HasPrefix(data, settingLanguage) {
+		editedMessage = tgbotapi.NewEditMessageText(chatID, messageID, languageMessage(language, preferences))
+		editedMessage.ReplyMarkup = buildLanguageKeyboard(language)
+	} else {
+		editedMessage = tgbotapi.NewEditMessageText(chatID, messageID, settingsMessage(language, preferences, isGroup))
+		editedMessage.ReplyMarkup = buildSettingsKeyboard(language, preferences, isGroup)
+	}
+
+	// Need to send CallBackConfig back, so that button stops the loading animation
+	callBackID := update.CallbackQuery.ID
+	return registrationReply{replyMessage: editedMessage, callbackResponse: tgbotapi.NewCallback(callBackID, "")}
+}
+
+// preferencesOrDefault returns the user's preferences, or the default preferences if they cannot be retrieved
+func preferencesOrDefault(chatID int64) Preferences {
+	preferences, err := preferencesDB.GetPreferences(chatID)
+	if err != nil {
+		logWarn("Unable to load preferences, using the defaults", "chat_id", chatID, "err", err)
+	}
+	return preferences
+}
+
+func settingsMessage(language string, preferences Preferences, isGroup bool) string {
+	lines := []string{
+		translate(language, "settings.title"),
+		"",
+		translate(language, "settings.layout", layoutName(language, preferences)),
+		translate(language, "settings.time", timeName(language, preferences)),
+		translate(language, "settings.description", descriptionName(language, preferences)),
+	}
+	if isGroup {
+		lines = append(lines, "", translate(language, "settings.managers", managersName(language, preferences)))
+	}
+	lines = append(lines, "", translate(language, "settings.tapToChange"))
+	return strings.Join(lines, "\n")
+}
+
+func buildSettingsKeyboard(language string, preferences Preferences, isGroup bool) *tgbotapi.InlineKeyboardMarkup {
+	var settingsKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "settings.layout", layoutName(language, preferences)), settingLayout),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "settings.time", timeName(language, preferences)), settingTime),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "settings.description", descriptionName(language, preferences)), settingDescription),
+		),
+	)
+	if isGroup {
+		settingsKeyboard.InlineKeyboard = append(settingsKeyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(language, "settings.managersButton", managersName(language, preferences)), settingAdminsOnly),
+		))
+	}
+	return &settingsKeyboard
+}
+
+// languageMessage asks which language to use, showing the language chosen with /language if there is one
+func languageMessage(language string, preferences Preferences) string {
+	current := translate(language, "language.automatic")
+	if preferences.Language != "" {
+		current = translate(preferences.Language, "language.name")
+	}
+	return translate(language, "language.ask", current)
+}
+
+// buildLanguageKeyboard lists every language in its own name, so that users can find theirs whichever language the menu is in
+func buildLanguageKeyboard(language string) *tgbotapi.InlineKeyboardMarkup {
+	var languageKeyboard = tgbotapi.NewInlineKeyboardMarkup()
+	for _, code := range languages {
+		languageKeyboard.InlineKeyboard = append(languageKeyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translate(code, "language.name"), settingLanguage+code),
+		))
+	}
+	languageKeyboard.InlineKeyboard = append(languageKeyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(translate(language, "language.automatic"), settingLanguage+automaticLanguage),
+	))
+	return &languageKeyboard
+}
+
+func layoutName(language string, preferences Preferences) string {
+	if preferences.Verbose {
+		return translate(language, "settings.verbose")
+	}
+	return translate(language, "settings.compact")
+}
+
+func timeName(language string, preferences Preferences) string {
+	if preferences.ClockTime {
+		return translate(language, "settings.clockTime")
+	}
+	return translate(language, "settings.minutesFromNow")
+}
+
+func descriptionName(language string, preferences Preferences) string {
+	if preferences.HideBusStopDescription {
+		return translate(language, "settings.hidden")
+	}
+	return translate(language, "settings.shown")
+}
+
+func managersName(language string, preferences Preferences) string {
+	if preferences.AdminsOnly {
+		return translate(language, "settings.adminsOnly")
+	}
+	return translate(language, "settings.everyone")
+}
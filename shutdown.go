@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Time given to each step of the shutdown before it is abandoned
+const shutdownTimeout time.Duration = 10 * time.Second
+
+// shutdown stops taking updates, waits for running cron jobs and background replies, then sends what is left in the outgoing queues.
+// The stores are closed by main after this returns
+func shutdown(stopUpdates chan struct{}, updatesStopped chan struct{}) {
+	logInfo("Stopping updates")
+	if webhookServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := webhookServer.Shutdown(ctx); err != nil {
+			logWarn("Unable to shut down webhook server", "err", err)
+		}
+		cancel()
+	} else {
+		bot.StopReceivingUpdates()
+	}
+	close(stopUpdates)
+	if !waitUntilDone(updatesStopped, shutdownTimeout) {
+		logWarn("Gave up waiting for the update being handled")
+	}
+
+	logInfo("Waiting for running cron jobs")
+	if !waitUntilDone(cronner.Stop().Done(), shutdownTimeout) {
+		logWarn("Gave up waiting for running cron jobs")
+	}
+	if backupCronner != nil && !waitUntilDone(backupCronner.Stop().Done(), shutdownTimeout) {
+		logWarn("Gave up waiting for running backup")
+	}
+
+	// The answer channels are closed once the update being handled is done, which may be after the wait for updates gave up
+	answersSent := make(chan struct{})
+	go func() {
+		answerSenders.Wait()
+		close(answersSent)
+	}()
+	if !waitUntilDone(answersSent, shutdownTimeout) {
+		logWarn("Gave up answering remaining callbacks, inline queries and background replies")
+	}
+
+	// Background replies are pushed to the outgoing queues, so the queues are drained after them
+	logInfo("Sending remaining outgoing messages")
+	if !outgoingMessages.Drain(shutdownTimeout) {
+		logWarn("Gave up sending remaining outgoing messages")
+	}
+
+	// Metrics are served until the end, so that the shutdown can be watched
+	if monitoringServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := monitoringServer.Shutdown(ctx); err != nil {
+			logWarn("Unable to shut down monitoring server", "err", err)
+		}
+		cancel()
+	}
+}
+
+// waitUntilDone returns true if done is closed before timeout
+func waitUntilDone(done <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
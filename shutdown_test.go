@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnswerChannelsCloseWhenUpdatesStop(t *testing.T) {
+	initOutgoingChannels()
+	stop := make(chan struct{})
+	updatesStopped := make(chan struct{})
+	go func() {
+		handleIncomingMessages(stop)
+		close(updatesStopped)
+	}()
+
+	select {
+	case <-outgoingCallbackResponses:
+		t.Fatal("Callback responses should stay open while updates are handled")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(stop)
+	if !waitUntilDone(updatesStopped, time.Second) {
+		t.Fatal("Updates did not stop")
+	}
+	if _, ok := <-outgoingCallbackResponses; ok {
+		t.Errorf("Callback responses should be closed once updates stop")
+	}
+	if _, ok := <-incomingInlineQueries; ok {
+		t.Errorf("Inline queries should be closed once updates stop")
+	}
+	if _, ok := <-backgroundReplies; ok {
+		t.Errorf("Background replies should be closed once updates stop")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Time to wait for another process to release the lock on a database file
+const storeOpenTimeout time.Duration = 5 * time.Second
+
+// JobStore contains the operations to store/retrieve/delete registered bus alarm jobs
+type JobStore interface {
+	StoreJob(newBusInfoJob BusInfoJob) (BusInfoJob, error)
+	GetJobsByDay(weekday time.Weekday) ([]BusInfoJob, error)
+	GetJobsByChatID(chatID int64) ([]BusInfoJob, error)
+	GetAllJobs() ([]BusInfoJob, error)
+	DeleteJob(jobID uint64) error
+	UpdateJob(busInfoJob BusInfoJob) error
+	MoveJobs(fromChatID int64, toChatID int64) ([]BusInfoJob, error)
+}
+
+// UserStateStore contains the operations to store/retrieve/delete the stage of registration that users are at
+type UserStateStore interface {
+	GetUserState(chatID int64) (*UserState, error)
+	SaveUserState(chatID int64, userState UserState) error
+	DeleteUserState(chatID int64) error
+}
+
+// PreferencesStore contains the operations to store/retrieve user preferences
+type PreferencesStore interface {
+	GetPreferences(chatID int64) (Preferences, error)
+	SavePreferences(chatID int64, preferences Preferences) error
+}
+
+// Store holds a long-lived handle to each bolt database, and the repositories that operate on them.
+// It should be opened once at start-up and closed on shutdown.
+// A Store from NewMemoryStore has no databases and keeps everything in memory instead
+type Store struct {
+	jobDB         *bolt.DB
+	userStateDB   *bolt.DB
+	preferencesDB *bolt.DB
+
+	Jobs        JobStore
+	UserStates  UserStateStore
+	Preferences PreferencesStore
+}
+
+// OpenStore opens the job, user state and preferences databases, and migrates them to the latest schema
+func OpenStore(jobDBFile string, userStateDBFile string, preferencesDBFile string) (*Store, error) {
+	store, err := OpenStoreWithoutMigrating(jobDBFile, userStateDBFile, preferencesDBFile)
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := store.Migrate(false)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	for _, report := range reports {
+		logInfo("Migrated database", "db", report.dbFile, "version", report.version, "description", report.description, "changes", len(report.changes))
+	}
+
+	inconsistencies, err := store.CheckJobConsistency(true)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	for _, inconsistency := range inconsistencies {
+		logWarn("Repaired job database", "inconsistency", inconsistency)
+	}
+	return store, nil
+}
+
+// OpenStoreWithoutMigrating opens the job, user state and preferences databases as they are
+func OpenStoreWithoutMigrating(jobDBFile string, userStateDBFile string, preferencesDBFile string) (*Store, error) {
+	store := &Store{}
+	var err error
+
+	if store.jobDB, err = openBoltDB(jobDBFile); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if store.userStateDB, err = openBoltDB(userStateDBFile); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if store.preferencesDB, err = openBoltDB(preferencesDBFile); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	store.Jobs = NewJobDB(store.jobDB)
+	store.UserStates = NewUserStateDB(store.userStateDB)
+	store.Preferences = NewPreferencesDB(store.preferencesDB)
+	return store, nil
+}
+
+// storeMigrationReport describes the changes made by a migration to one of the databases
+type storeMigrationReport struct {
+	dbFile string
+	migrationReport
+}
+
+// Migrate runs the pending migrations of every database.
+// With dryRun, nothing is changed and the reports describe what would have been changed
+func (s *Store) Migrate(dryRun bool) ([]storeMigrationReport, error) {
+	if s.jobDB == nil {
+		return nil, nil
+	}
+
+	databases := []struct {
+		db         *bolt.DB
+		migrations []migration
+	}{
+		{s.jobDB, NewJobDB(s.jobDB).migrations()},
+		{s.userStateDB, NewUserStateDB(s.userStateDB).migrations()},
+		{s.preferencesDB, NewPreferencesDB(s.preferencesDB).migrations()},
+	}
+
+	storeReports := []storeMigrationReport{}
+	for _, database := range databases {
+		reports, err := runMigrations(database.db, database.migrations, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range reports {
+			storeReports = append(storeReports, storeMigrationReport{dbFile: database.db.Path(), migrationReport: report})
+		}
+	}
+	return storeReports, nil
+}
+
+// CheckJobConsistency checks that the job database's index buckets agree with its alarms, repairing them with repair.
+// Returns a description of every inconsistency found
+func (s *Store) CheckJobConsistency(repair bool) ([]string, error) {
+	if s.jobDB == nil {
+		return nil, nil
+	}
+	return NewJobDB(s.jobDB).CheckConsistency(repair)
+}
+
+// NewMemoryStore returns a Store that keeps everything in memory
+func NewMemoryStore() *Store {
+	return &Store{
+		Jobs:        NewMemoryJobDB(),
+		UserStates:  NewMemoryUserStateDB(),
+		Preferences: NewMemoryPreferencesDB(),
+	}
+}
+
+func openBoltDB(dbFile string) (*bolt.DB, error) {
+	return bolt.Open(dbFile, 0600, &bolt.Options{Timeout: storeOpenTimeout})
+}
+
+// Ping checks that every opened database can still be read, a Store from NewMemoryStore always can
+func (s *Store) Ping() error {
+	for _, db := range []*bolt.DB{s.jobDB, s.userStateDB, s.preferencesDB} {
+		if db == nil {
+			continue
+		}
+		if err := db.View(func(tx *bolt.Tx) error { return nil }); err != nil {
+			return fmt.Errorf("%s: %v", db.Path(), err)
+		}
+	}
+	return nil
+}
+
+// Close closes every opened database, returning the first error encountered
+func (s *Store) Close() error {
+	var firstErr error
+	for _, db := range []*bolt.DB{s.jobDB, s.userStateDB, s.preferencesDB} {
+		if db == nil {
+			continue
+		}
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
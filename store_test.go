@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// setUpBenchmarkJobs stores the alarms of 100 users in a job database within a temporary directory,
+// which the caller removes
+func setUpBenchmarkJobs(b *testing.B) string {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		b.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "job.db"), 0600, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	jobDB := NewJobDB(db)
+	for i := 0; i < 100; i++ {
+		jobDB.StoreJob(BusInfoJob{ChatID: int64(i), BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}})
+	}
+	db.Close()
+	return dir
+}
+
+// Lookup with the long-lived handle that Store keeps
+func BenchmarkGetJobsByChatIDWithStore(b *testing.B) {
+	dir := setUpBenchmarkJobs(b)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenStore(filepath.Join(dir, "job.db"), filepath.Join(dir, "user_state.db"), filepath.Join(dir, "preferences.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Jobs.GetJobsByChatID(int64(i % 100))
+	}
+}
+
+// Lookup that opens and closes the database every time, as every operation used to
+func BenchmarkGetJobsByChatIDWithOpenPerOperation(b *testing.B) {
+	dir := setUpBenchmarkJobs(b)
+	defer os.RemoveAll(dir)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db, err := bolt.Open(filepath.Join(dir, "job.db"), 0600, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		jobDB := NewJobDB(db)
+		jobDB.GetJobsByChatID(int64(i % 100))
+		db.Close()
+	}
+}
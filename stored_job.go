@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -26,290 +28,343 @@ func (s *ScheduledTime) ToCronExpression(day time.Weekday) string {
 	return fmt.Sprintf("%d %d * * %d", s.Minute, s.Hour, day)
 }
 
-// BusInfoJob contains all information of a registered bus alarm
+// BusInfoJob contains all information of a registered bus alarm,
+// ID is assigned when the bus alarm is stored and stays the same for the lifetime of the alarm
 type BusInfoJob struct {
+	ID            uint64
 	ChatID        int64
 	BusStopCode   string
 	BusServiceNo  string
 	ScheduledTime ScheduledTime
-	Weekday       time.Weekday
+	Weekdays      []time.Weekday
 }
 
-// JobDB contains the operations to store/retrieve/delete registered bus alarm jobs
-type JobDB struct {
-	dbFile     string
-	userBucket string
-	jobBucket  string
-}
-
-// NewJobDB returns an initialised instance of JobDB
-func NewJobDB(dbFile string) JobDB {
-	return JobDB{dbFile: dbFile, userBucket: "users", jobBucket: "jobs"}
-}
-
-// StoreJob stores the registered bus alarm into the database
-func (s *JobDB) StoreJob(newBusInfoJob BusInfoJob) {
-
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
+// HasWeekday returns true if the bus alarm goes off on the given day
+func (b *BusInfoJob) HasWeekday(day time.Weekday) bool {
+	for _, weekday := range b.Weekdays {
+		if weekday == day {
+			return true
+		}
 	}
-	defer db.Close()
-
-	db.Update(func(tx *bolt.Tx) error {
+	return false
+}
 
-		s.storeJob(newBusInfoJob, tx)
-		s.storeJobForLookup(newBusInfoJob, tx)
-		return nil
-	})
+// IsSameAlarm returns true if both bus alarms are for the same bus at the same bus stop and time in the same chat,
+// whichever days they go off on
+func (b *BusInfoJob) IsSameAlarm(other BusInfoJob) bool {
+	return b.ChatID == other.ChatID && b.BusStopCode == other.BusStopCode && b.BusServiceNo == other.BusServiceNo && b.ScheduledTime == other.ScheduledTime
 }
 
-// User bucket: ChatID (Key) -> Registered jobs for this user (Value)
-func (s *JobDB) storeJob(newBusInfoJob BusInfoJob, tx *bolt.Tx) {
-	userKey := []byte(strconv.FormatInt(newBusInfoJob.ChatID, 10))
-	b, err := tx.CreateBucketIfNotExists([]byte(s.userBucket))
+// storeOrMergeJob stores the bus alarm, unless the chat already has the same alarm on other days, in which case
+// the days are added to the existing alarm so that each alarm only goes off once. Returns the stored alarm,
+// and the days of the new alarm that the existing alarm already had; the alarm is a duplicate if it had all of them
+func storeOrMergeJob(jobStore JobStore, newBusInfoJob BusInfoJob) (BusInfoJob, []time.Weekday, error) {
+	// Bad entries are skipped, so that the alarm is still merged with the rest of the chat's alarms
+	jobs, err := jobStore.GetJobsByChatID(newBusInfoJob.ChatID)
 	if err != nil {
-		log.Fatalln(err)
+		logError("Unable to load all of the chat's jobs", "chat_id", newBusInfoJob.ChatID, "err", err)
 	}
-	storedJobs := b.Get(userKey)
-	if storedJobs == nil {
-		encBusInfoJobs, err := json.Marshal([]BusInfoJob{newBusInfoJob})
-		if err != nil {
-			log.Fatalln(err)
+	for _, job := range jobs {
+		if !job.IsSameAlarm(newBusInfoJob) {
+			continue
 		}
-		log.Println("New job:", newBusInfoJob)
-		b.Put(userKey, encBusInfoJobs)
-	} else {
-		existingBusInfoJobs := []BusInfoJob{}
-		json.Unmarshal(storedJobs, &existingBusInfoJobs)
-
-		for _, s := range existingBusInfoJobs {
-			if newBusInfoJob == s {
-				log.Println("Job already exists:", newBusInfoJob)
+		coveredDays := []time.Weekday{}
+		for _, weekday := range newBusInfoJob.Weekdays {
+			if job.HasWeekday(weekday) {
+				coveredDays = append(coveredDays, weekday)
+			} else {
+				job.Weekdays = append(job.Weekdays, weekday)
 			}
 		}
-		encBusInfoJobs, err := json.Marshal(append(existingBusInfoJobs, newBusInfoJob))
-		if err != nil {
-			log.Fatalln(err)
+		if len(coveredDays) == len(newBusInfoJob.Weekdays) {
+			return job, coveredDays, nil
 		}
-
-		log.Println("Adding to Chat ID to Job bucket", append(existingBusInfoJobs, newBusInfoJob))
-		b.Put(userKey, encBusInfoJobs)
+		sort.Slice(job.Weekdays, func(i, j int) bool {
+			return job.Weekdays[i] < job.Weekdays[j]
+		})
+		if err := jobStore.UpdateJob(job); err != nil {
+			return BusInfoJob{}, nil, err
+		}
+		logInfo("Merged job", "job_id", job.ID, "chat_id", job.ChatID)
+		return job, coveredDays, nil
 	}
 
+	job, err := jobStore.StoreJob(newBusInfoJob)
+	return job, []time.Weekday{}, err
 }
 
-// Lookup bucket: Weekday (Key) -> Chat IDs with jobs for the day (Value)
-func (s *JobDB) storeJobForLookup(newBusInfoJob BusInfoJob, tx *bolt.Tx) error {
-	dayKey := []byte(newBusInfoJob.Weekday.String())
-	b, err := tx.CreateBucketIfNotExists([]byte(s.jobBucket))
-	if err != nil {
-		log.Fatalln(err)
-	}
-	storedChatIDs := b.Get(dayKey)
-	if storedChatIDs == nil {
-		chatIDsToStore := []int64{newBusInfoJob.ChatID}
-		encChatID, err := json.Marshal(chatIDsToStore)
+// JobDB contains the operations to store/retrieve/delete registered bus alarm jobs
+type JobDB struct {
+	db            *bolt.DB
+	alarmBucket   string
+	chatIDBucket  string
+	weekdayBucket string
+}
+
+// NewJobDB returns an initialised instance of JobDB
+func NewJobDB(db *bolt.DB) *JobDB {
+	return &JobDB{db: db, alarmBucket: "alarms", chatIDBucket: "alarmsByChatID", weekdayBucket: "alarmsByWeekday"}
+}
+
+// Bolt keys of alarms are their IDs in big endian, so that they are iterated in the order of creation
+func alarmKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func chatIDKey(chatID int64) []byte {
+	return []byte(strconv.FormatInt(chatID, 10))
+}
+
+// StoreJob assigns an ID to the registered bus alarm and stores it into the database
+func (s *JobDB) StoreJob(newBusInfoJob BusInfoJob) (BusInfoJob, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(s.alarmBucket))
 		if err != nil {
-			log.Fatalln(err)
-		}
-		log.Println("New Chat ID:", newBusInfoJob.ChatID)
-		b.Put(dayKey, encChatID)
-	} else {
-		existingChatIDs := []int64{}
-		json.Unmarshal(storedChatIDs, &existingChatIDs)
-
-		for _, s := range existingChatIDs {
-			if newBusInfoJob.ChatID == s {
-				log.Println("Chat ID already exists in the lookup at this key:", newBusInfoJob)
-				return nil
-			}
+			return err
 		}
-		encChatIDs, err := json.Marshal(append(existingChatIDs, newBusInfoJob.ChatID))
+		newBusInfoJob.ID, err = b.NextSequence()
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
+		return s.putJob(newBusInfoJob, tx)
+	})
 
-		log.Println("Adding to Weekday to ChatID bucket", append(encChatIDs))
-		b.Put(dayKey, encChatIDs)
+	if err != nil {
+		return BusInfoJob{}, err
 	}
-	return nil
+	logInfo("Stored job", "job_id", newBusInfoJob.ID, "chat_id", newBusInfoJob.ChatID)
+	return newBusInfoJob, nil
 }
 
-// GetJobsByDay retrieves all bus alarms for the particular given day
-func (s *JobDB) GetJobsByDay(weekday time.Weekday) []BusInfoJob {
-	jobsOnDay := []BusInfoJob{}
+// Alarm bucket: ID (Key) -> Bus alarm (Value)
+// ChatID bucket: ChatID (Nested bucket) -> IDs of the user's bus alarms (Keys)
+// Weekday bucket: Weekday (Nested bucket) -> IDs of bus alarms on the day (Keys)
+func (s *JobDB) putJob(busInfoJob BusInfoJob, tx *bolt.Tx) error {
+	key := alarmKey(busInfoJob.ID)
 
-	db, err := bolt.Open(s.dbFile, 0600, nil)
+	b, err := tx.CreateBucketIfNotExists([]byte(s.alarmBucket))
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+	encBusInfoJob, err := json.Marshal(busInfoJob)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(key, encBusInfoJob); err != nil {
+		return err
 	}
-	defer db.Close()
-
-	err = db.View(func(tx *bolt.Tx) error {
 
-		chatIDs := s.getChatIDsByDay(weekday, tx)
+	chatIDIndex, err := createNestedBucketIfNotExists(tx, s.chatIDBucket, chatIDKey(busInfoJob.ChatID))
+	if err != nil {
+		return err
+	}
+	if err := chatIDIndex.Put(key, []byte{}); err != nil {
+		return err
+	}
 
-		if len(chatIDs) == 0 {
-			return nil
+	for _, weekday := range busInfoJob.Weekdays {
+		weekdayIndex, err := createNestedBucketIfNotExists(tx, s.weekdayBucket, []byte(weekday.String()))
+		if err != nil {
+			return err
 		}
-
-		for _, chatID := range chatIDs {
-			userJobsOnDay := s.getJobsByChatIDandDay(chatID, weekday, tx)
-			if len(userJobsOnDay) == 0 {
-				log.Panicln("Desync of information between the two buckets")
-			}
-			jobsOnDay = append(jobsOnDay, userJobsOnDay...)
+		if err := weekdayIndex.Put(key, []byte{}); err != nil {
+			return err
 		}
-		return nil
-	})
+	}
+	return nil
+}
 
+func createNestedBucketIfNotExists(tx *bolt.Tx, bucket string, nestedBucket []byte) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(bucket))
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-
-	return jobsOnDay
+	return b.CreateBucketIfNotExists(nestedBucket)
 }
 
-func (s *JobDB) getChatIDsByDay(weekday time.Weekday, tx *bolt.Tx) []int64 {
-	dayKey := []byte(weekday.String())
-
-	b := tx.Bucket([]byte(s.jobBucket))
+func nestedBucket(tx *bolt.Tx, bucket string, nestedBucket []byte) *bolt.Bucket {
+	b := tx.Bucket([]byte(bucket))
 	if b == nil {
 		return nil
 	}
+	return b.Bucket(nestedBucket)
+}
 
-	// List of Chat IDs that has jobs for the day
-	storedChatIDs := b.Get(dayKey)
+// getJobsByIndex retrieves the bus alarms whose IDs are the keys of the given index bucket.
+// Index entries of missing or undecodable alarms are skipped, the returned error describes them
+// while the rest of the alarms are still returned
+func (s *JobDB) getJobsByIndex(index *bolt.Bucket, tx *bolt.Tx) ([]BusInfoJob, error) {
+	jobs := []BusInfoJob{}
+	if index == nil {
+		return jobs, nil
+	}
 
-	decodedChatIDs := []int64{}
-	err := json.Unmarshal(storedChatIDs, &decodedChatIDs)
-	if err != nil {
-		log.Fatalln(err)
+	b := tx.Bucket([]byte(s.alarmBucket))
+	badEntries := []string{}
+	index.ForEach(func(key []byte, _ []byte) error {
+		var v []byte
+		if b != nil {
+			v = b.Get(key)
+		}
+		if v == nil {
+			badEntries = append(badEntries, fmt.Sprintf("Alarm %d is indexed but does not exist", binary.BigEndian.Uint64(key)))
+			return nil
+		}
+		job, err := decodeJob(key, v)
+		if err != nil {
+			badEntries = append(badEntries, err.Error())
+			return nil
+		}
+		jobs = append(jobs, job)
+		return nil
+	})
+
+	if len(badEntries) > 0 {
+		return jobs, fmt.Errorf("Skipped %d bad entries: %s", len(badEntries), strings.Join(badEntries, "; "))
 	}
-	return decodedChatIDs
+	return jobs, nil
 }
 
-func (s *JobDB) getJobsByChatIDandDay(chatID int64, weekday time.Weekday, tx *bolt.Tx) []BusInfoJob {
-	b := tx.Bucket([]byte(s.userBucket))
-	if b == nil {
-		return nil
+func decodeJob(key []byte, v []byte) (BusInfoJob, error) {
+	job := BusInfoJob{}
+	if err := json.Unmarshal(v, &job); err != nil {
+		return job, fmt.Errorf("Unable to decode alarm %d: %v", binary.BigEndian.Uint64(key), err)
 	}
+	return job, nil
+}
 
-	userKey := []byte(strconv.FormatInt(chatID, 10))
-	v := b.Get(userKey)
-	storedJobs := []BusInfoJob{}
-	json.Unmarshal(v, &storedJobs)
+// GetJobsByDay retrieves all bus alarms for the particular given day
+func (s *JobDB) GetJobsByDay(weekday time.Weekday) ([]BusInfoJob, error) {
+	var jobsOnDay []BusInfoJob
 
-	storedJobsForDay := []BusInfoJob{}
-	for _, job := range storedJobs {
-		if job.Weekday == weekday {
-			storedJobsForDay = append(storedJobsForDay, job)
-		}
-	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		jobsOnDay, err = s.getJobsByIndex(nestedBucket(tx, s.weekdayBucket, []byte(weekday.String())), tx)
+		return err
+	})
 
-	return storedJobsForDay
+	return jobsOnDay, err
 }
 
 // GetJobsByChatID retrieves all bus alarms registered by a user identified by a ChatID
-func (s *JobDB) GetJobsByChatID(chatID int64) []BusInfoJob {
-	userKey := []byte(strconv.FormatInt(chatID, 10))
-	storedJobs := []BusInfoJob{}
+func (s *JobDB) GetJobsByChatID(chatID int64) ([]BusInfoJob, error) {
+	var storedJobs []BusInfoJob
 
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		storedJobs, err = s.getJobsByIndex(nestedBucket(tx, s.chatIDBucket, chatIDKey(chatID)), tx)
+		return err
+	})
 
-	err = db.View(func(tx *bolt.Tx) error {
+	return storedJobs, err
+}
+
+// GetAllJobs retrieves every registered bus alarm, in the order that they were stored.
+// Undecodable alarms are skipped, the returned error describes them while the rest of the alarms are still returned
+func (s *JobDB) GetAllJobs() ([]BusInfoJob, error) {
+	jobs := []BusInfoJob{}
+	badEntries := []string{}
 
-		b := tx.Bucket([]byte(s.userBucket))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.alarmBucket))
 		if b == nil {
 			return nil
 		}
-
-		v := b.Get(userKey)
-		json.Unmarshal(v, &storedJobs)
-		return nil
+		return b.ForEach(func(key []byte, v []byte) error {
+			job, err := decodeJob(key, v)
+			if err != nil {
+				badEntries = append(badEntries, err.Error())
+				return nil
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
 	})
 
-	if err != nil {
-		log.Fatalln(err)
+	if err == nil && len(badEntries) > 0 {
+		err = fmt.Errorf("Skipped %d bad entries: %s", len(badEntries), strings.Join(badEntries, "; "))
 	}
-
-	return storedJobs
+	return jobs, err
 }
 
-// DeleteJob deletes the given job from the database
-func (s *JobDB) DeleteJob(jobToDelete BusInfoJob) {
-	chatID := jobToDelete.ChatID
-
-	userKey := []byte(strconv.FormatInt(chatID, 10))
-
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	err = db.Update(func(tx *bolt.Tx) error {
+// DeleteJob deletes the bus alarm with the given ID from the database
+func (s *JobDB) DeleteJob(jobID uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.deleteJob(jobID, tx)
+	})
+}
 
-		b := tx.Bucket([]byte(s.userBucket))
-		if b == nil {
-			return nil
+// UpdateJob replaces the bus alarm that has the same ID
+func (s *JobDB) UpdateJob(busInfoJob BusInfoJob) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(s.alarmBucket)); b == nil || b.Get(alarmKey(busInfoJob.ID)) == nil {
+			return fmt.Errorf("Alarm %d does not exist", busInfoJob.ID)
+		}
+		if err := s.deleteJob(busInfoJob.ID, tx); err != nil {
+			return err
 		}
+		return s.putJob(busInfoJob, tx)
+	})
+}
 
-		v := b.Get(userKey)
-		storedJobs := []BusInfoJob{}
-		json.Unmarshal(v, &storedJobs)
+// MoveJobs moves the bus alarms of a chat to another chat, keeping their IDs, and returns the moved alarms
+func (s *JobDB) MoveJobs(fromChatID int64, toChatID int64) ([]BusInfoJob, error) {
+	var movedJobs []BusInfoJob
 
-		// Remove job and store the remaining back to the key
-		remainingJobs := storedJobs[:0]
-		for _, job := range storedJobs {
-			if job != jobToDelete {
-				remainingJobs = append(remainingJobs, job)
-			}
-		}
-		encRemainingJobs, err := json.Marshal(remainingJobs)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// Bad entries are skipped, so that the rest of the chat's alarms still move
+		jobs, err := s.getJobsByIndex(nestedBucket(tx, s.chatIDBucket, chatIDKey(fromChatID)), tx)
 		if err != nil {
-			log.Fatalln(err)
+			logError("Unable to move all of the chat's jobs", "chat_id", fromChatID, "new_chat_id", toChatID, "err", err)
 		}
-		b.Put(userKey, encRemainingJobs)
-
-		// Check and remove from the other Job bucket if ChatID has no jobs for that day anymore
-		removedJobDay := jobToDelete.Weekday
-		remainingJobsForDay := s.getJobsByChatIDandDay(chatID, removedJobDay, tx)
-
-		if len(remainingJobsForDay) == 0 {
-			s.deleteChatIDFromDayLookup(chatID, removedJobDay, tx)
+		for i := range jobs {
+			if err := s.deleteJob(jobs[i].ID, tx); err != nil {
+				return err
+			}
+			jobs[i].ChatID = toChatID
+			if err := s.putJob(jobs[i], tx); err != nil {
+				return err
+			}
 		}
-
+		movedJobs = jobs
 		return nil
 	})
+
+	return movedJobs, err
 }
 
-func (s *JobDB) deleteChatIDFromDayLookup(chatIDToDelete int64, weekday time.Weekday, tx *bolt.Tx) {
-	dayKey := []byte(weekday.String())
+func (s *JobDB) deleteJob(jobID uint64, tx *bolt.Tx) error {
+	key := alarmKey(jobID)
 
-	b := tx.Bucket([]byte(s.jobBucket))
+	b := tx.Bucket([]byte(s.alarmBucket))
 	if b == nil {
-		log.Fatalln("Unable to open job bucket deletion")
+		return nil
+	}
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	jobToDelete, err := decodeJob(key, v)
+	if err != nil {
+		return err
 	}
 
-	b.Get(dayKey)
-	storedChatIDs := []int64{}
-	json.Unmarshal(b.Get(dayKey), &storedChatIDs)
-
-	remainingChatIDs := storedChatIDs[:0]
-	for _, chatID := range storedChatIDs {
-		if chatID != chatIDToDelete {
-			remainingChatIDs = append(remainingChatIDs, chatID)
+	if err := b.Delete(key); err != nil {
+		return err
+	}
+	if chatIDIndex := nestedBucket(tx, s.chatIDBucket, chatIDKey(jobToDelete.ChatID)); chatIDIndex != nil {
+		if err := chatIDIndex.Delete(key); err != nil {
+			return err
 		}
 	}
-	encRemainingIDs, err := json.Marshal(remainingChatIDs)
-	if err != nil {
-		log.Fatalln(err)
+	for _, weekday := range jobToDelete.Weekdays {
+		if weekdayIndex := nestedBucket(tx, s.weekdayBucket, []byte(weekday.String())); weekdayIndex != nil {
+			if err := weekdayIndex.Delete(key); err != nil {
+				return err
+			}
+		}
 	}
-	b.Put(dayKey, encRemainingIDs)
+	return nil
 }
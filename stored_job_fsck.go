@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// jobDBInconsistency is a disagreement between the alarm bucket and its index buckets,
+// repair is nil if it cannot be repaired automatically
+type jobDBInconsistency struct {
+	description string
+	repair      func(tx *bolt.Tx) error
+}
+
+// CheckConsistency compares the alarm bucket against the ChatID and weekday index buckets,
+// looking for index entries of alarms that do not exist (or no longer belong there), and alarms missing from an index.
+// With repair, inconsistencies are fixed. Returns a description of every inconsistency found
+func (s *JobDB) CheckConsistency(repair bool) ([]string, error) {
+	descriptions := []string{}
+
+	check := func(tx *bolt.Tx) error {
+		inconsistencies, err := s.findInconsistencies(tx)
+		if err != nil {
+			return err
+		}
+		for _, inconsistency := range inconsistencies {
+			if !repair {
+				descriptions = append(descriptions, inconsistency.description)
+				continue
+			}
+			if inconsistency.repair == nil {
+				descriptions = append(descriptions, inconsistency.description+" (unable to repair)")
+				continue
+			}
+			if err := inconsistency.repair(tx); err != nil {
+				return err
+			}
+			descriptions = append(descriptions, inconsistency.description+" (repaired)")
+		}
+		return nil
+	}
+
+	var err error
+	if repair {
+		err = s.db.Update(check)
+	} else {
+		err = s.db.View(check)
+	}
+	return descriptions, err
+}
+
+// findInconsistencies only reads, as buckets cannot be changed while they are being iterated
+func (s *JobDB) findInconsistencies(tx *bolt.Tx) ([]jobDBInconsistency, error) {
+	inconsistencies := []jobDBInconsistency{}
+
+	// Where every alarm should be indexed
+	alarms := make(map[uint64]BusInfoJob)
+	if b := tx.Bucket([]byte(s.alarmBucket)); b != nil {
+		err := b.ForEach(func(key []byte, v []byte) error {
+			job, err := decodeJob(key, v)
+			if err != nil {
+				inconsistencies = append(inconsistencies, jobDBInconsistency{description: err.Error()})
+				return nil
+			}
+			alarms[binary.BigEndian.Uint64(key)] = job
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Index entries that should not be there
+	chatIDIndexed := make(map[uint64]bool)
+	if b := tx.Bucket([]byte(s.chatIDBucket)); b != nil {
+		err := b.ForEach(func(chatIDKey []byte, _ []byte) error {
+			chatIDIndex := b.Bucket(chatIDKey)
+			if chatIDIndex == nil {
+				return nil
+			}
+			chatID, _ := strconv.ParseInt(string(chatIDKey), 10, 64)
+			return chatIDIndex.ForEach(func(key []byte, _ []byte) error {
+				id := binary.BigEndian.Uint64(key)
+				job, ok := alarms[id]
+				if ok && job.ChatID == chatID {
+					chatIDIndexed[id] = true
+					return nil
+				}
+				inconsistencies = append(inconsistencies, s.orphanedIndexEntry(s.chatIDBucket, chatIDKey, id))
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	weekdayIndexed := make(map[uint64]map[time.Weekday]bool)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdayIndex := nestedBucket(tx, s.weekdayBucket, []byte(day.String()))
+		if weekdayIndex == nil {
+			continue
+		}
+		weekday := day
+		err := weekdayIndex.ForEach(func(key []byte, _ []byte) error {
+			id := binary.BigEndian.Uint64(key)
+			job, ok := alarms[id]
+			if ok && job.HasWeekday(weekday) {
+				if weekdayIndexed[id] == nil {
+					weekdayIndexed[id] = make(map[time.Weekday]bool)
+				}
+				weekdayIndexed[id][weekday] = true
+				return nil
+			}
+			inconsistencies = append(inconsistencies, s.orphanedIndexEntry(s.weekdayBucket, []byte(weekday.String()), id))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Alarms that are missing from an index, in the order that they were created
+	b := tx.Bucket([]byte(s.alarmBucket))
+	if b == nil {
+		return inconsistencies, nil
+	}
+	err := b.ForEach(func(key []byte, _ []byte) error {
+		id := binary.BigEndian.Uint64(key)
+		job, ok := alarms[id]
+		if !ok {
+			return nil
+		}
+		if !chatIDIndexed[id] {
+			inconsistencies = append(inconsistencies, s.missingIndexEntry(s.chatIDBucket, chatIDKey(job.ChatID), id))
+		}
+		for _, weekday := range job.Weekdays {
+			if !weekdayIndexed[id][weekday] {
+				inconsistencies = append(inconsistencies, s.missingIndexEntry(s.weekdayBucket, []byte(weekday.String()), id))
+			}
+		}
+		return nil
+	})
+	return inconsistencies, err
+}
+
+func (s *JobDB) orphanedIndexEntry(indexBucket string, nestedKey []byte, id uint64) jobDBInconsistency {
+	return jobDBInconsistency{
+		description: fmt.Sprintf("Orphaned entry for alarm %d in %s/%s", id, indexBucket, nestedKey),
+		repair: func(tx *bolt.Tx) error {
+			return nestedBucket(tx, indexBucket, nestedKey).Delete(alarmKey(id))
+		},
+	}
+}
+
+func (s *JobDB) missingIndexEntry(indexBucket string, nestedKey []byte, id uint64) jobDBInconsistency {
+	return jobDBInconsistency{
+		description: fmt.Sprintf("Missing entry for alarm %d in %s/%s", id, indexBucket, nestedKey),
+		repair: func(tx *bolt.Tx) error {
+			index, err := createNestedBucketIfNotExists(tx, indexBucket, nestedKey)
+			if err != nil {
+				return err
+			}
+			return index.Put(alarmKey(id), []byte{})
+		},
+	}
+}
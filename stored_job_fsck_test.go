@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestCheckConsistencyRepairsIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	jobDB := NewJobDB(db)
+	storedJob, _ := jobDB.StoreJob(BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}})
+
+	// Alarm goes missing from the ChatID index, and a deleted alarm is left behind in the weekday index
+	db.Update(func(tx *bolt.Tx) error {
+		nestedBucket(tx, jobDB.chatIDBucket, chatIDKey(12345)).Delete(alarmKey(storedJob.ID))
+		nestedBucket(tx, jobDB.weekdayBucket, []byte(time.Monday.String())).Put(alarmKey(99), []byte{})
+		return nil
+	})
+
+	jobsOnDay, err := jobDB.GetJobsByDay(time.Monday)
+	if err == nil || len(jobsOnDay) != 1 {
+		t.Errorf("Orphaned entry should be skipped and reported, got %v and %v", jobsOnDay, err)
+	}
+
+	inconsistencies, err := jobDB.CheckConsistency(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 2 {
+		t.Errorf("Expected 2 inconsistencies but got %v", inconsistencies)
+	}
+
+	if _, err := jobDB.CheckConsistency(true); err != nil {
+		t.Fatal(err)
+	}
+	if inconsistencies, _ := jobDB.CheckConsistency(false); len(inconsistencies) != 0 {
+		t.Errorf("Inconsistencies should be repaired but found %v", inconsistencies)
+	}
+	if storedJobs, _ := jobDB.GetJobsByChatID(12345); len(storedJobs) != 1 {
+		t.Errorf("Alarm should be found by ChatID after repair")
+	}
+	if jobsOnDay, err := jobDB.GetJobsByDay(time.Monday); err != nil || len(jobsOnDay) != 1 {
+		t.Errorf("Orphaned entry should be removed after repair")
+	}
+}
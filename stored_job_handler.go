@@ -1,40 +1,26 @@
 package main
 
 import (
-	"log"
-	"time"
-
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/robfig/cron/v3"
 )
 
 func handleStoredJobs() {
-	cronner = cron.New()
+	cronner = cron.New(cron.WithLocation(location))
 	addTodayJobsToCronner(cronner)
 	cronner.Start()
 
-	// Debugging
-	log.Print("Starting jobs: ")
-	for _, entry := range cronner.Entries() {
-		log.Println(entry)
-	}
-
 	refreshCronner := func() {
-		log.Println("Old jobs:")
 		for _, entry := range cronner.Entries() {
-			// Debugging
-			log.Println(entry)
+			// Snoozes that have yet to fire are kept, as they are not stored anywhere else
+			if _, ok := entry.Job.(snoozeCronJob); ok && !entry.Next.IsZero() {
+				continue
+			}
 			if entry.ID != refreshCronEntryID {
 				cronner.Remove(entry.ID)
 			}
 		}
-
 		addTodayJobsToCronner(cronner)
-
-		log.Println("New jobs: ")
-		for _, entry := range cronner.Entries() {
-			log.Println(entry)
-		}
 	}
 
 	// Daily jobs are loaded at midnight, so that cron does not contain all jobs
@@ -42,31 +28,82 @@ func handleStoredJobs() {
 }
 
 func addTodayJobsToCronner(cronner *cron.Cron) {
-	today := time.Now().Weekday()
-	jobs := storedJobDB.GetJobsByDay(today)
+	today := now().Weekday()
+	// Bad entries are skipped, so that the rest of today's jobs still run
+	jobs, err := storedJobDB.GetJobsByDay(today)
+	if err != nil {
+		logError("Unable to load all of today's jobs", "err", err)
+	}
 	for _, job := range jobs {
-		// Debugging
-		log.Println("Job:", job)
-		log.Println("Cron expression", job.ScheduledTime.ToCronExpression(today))
 		addJobtoCronner(cronner, job)
 	}
+	logInfo("Loaded today's jobs", "weekday", today, "jobs", len(jobs))
+}
+
+// alarmCronJob is a bus alarm in cronner, which keeps the alarm so that the owner of each cron entry is known
+type alarmCronJob struct {
+	BusInfoJob
+}
+
+// Run sends the arrival information of the bus alarm
+func (j alarmCronJob) Run() {
+	logInfo("Alarm fired", "job_id", j.ID, "chat_id", j.ChatID)
+	todayStats.AlarmFired()
+	alarmsFired.Inc()
+	fetchAndPushInfo(j.BusInfoJob)
 }
 
 func addJobtoCronner(cronner *cron.Cron, busInfoJob BusInfoJob) {
-	log.Println("Added", busInfoJob, "job to today's cronner")
-	cronner.AddFunc(busInfoJob.ScheduledTime.ToCronExpression(time.Now().Weekday()), func() {
-		fetchAndPushInfo(busInfoJob)
-	})
+	cronExpression := busInfoJob.ScheduledTime.ToCronExpression(now().Weekday())
+	if _, err := cronner.AddJob(cronExpression, alarmCronJob{busInfoJob}); err != nil {
+		logError("Unable to schedule job", "job_id", busInfoJob.ID, "cron_expression", cronExpression, "err", err)
+		return
+	}
+	logDebug("Scheduled job", "job_id", busInfoJob.ID, "chat_id", busInfoJob.ChatID, "cron_expression", cronExpression)
 }
 
-func fetchAndPushInfo(busJob BusInfoJob) {
-	log.Println("Fetching information to push")
-	busArrivalInformation := fetchBusArrivalInformation(busJob.BusStopCode, busJob.BusServiceNo)
-	textMessage := busArrivalInformation.toMessageString()
-	sendOutgoingMessage(busJob.ChatID, textMessage)
+// rescheduleMovedJobs replaces today's cron entries of a chat's alarms, after the alarms have moved to another chat
+func rescheduleMovedJobs(cronner *cron.Cron, fromChatID int64, movedJobs []BusInfoJob) {
+	for _, entry := range cronner.Entries() {
+		if alarm, ok := entry.Job.(alarmCronJob); ok && alarm.ChatID == fromChatID {
+			cronner.Remove(entry.ID)
+		}
+	}
+	today := now().Weekday()
+	for _, job := range movedJobs {
+		if job.HasWeekday(today) {
+			addJobtoCronner(cronner, job)
+		}
+	}
 }
 
-func sendOutgoingMessage(chatID int64, textMessage string) {
-	messageToSend := tgbotapi.NewMessage(chatID, textMessage)
-	outgoingMessages <- messageToSend
+// rescheduleJob replaces today's cron entry of an alarm that has been stored or changed, so that it goes off once if it is on today
+func rescheduleJob(cronner *cron.Cron, busInfoJob BusInfoJob) {
+	for _, entry := range cronner.Entries() {
+		if alarm, ok := entry.Job.(alarmCronJob); ok && alarm.ID == busInfoJob.ID {
+			cronner.Remove(entry.ID)
+		}
+	}
+	if busInfoJob.HasWeekday(now().Weekday()) {
+		addJobtoCronner(cronner, busInfoJob)
+	}
+}
+
+func fetchAndPushInfo(busJob BusInfoJob) {
+	preferences := preferencesOrDefault(busJob.ChatID)
+	language := languageOf(preferences)
+	busArrivalInformation, err := fetchBusArrivalInformation(busJob.BusStopCode, busJob.BusServiceNo)
+	if err != nil {
+		logWarn("Unable to fetch bus arrivals for alarm", "job_id", busJob.ID, "chat_id", busJob.ChatID, "err", err)
+		// The user is still told in time, but the notification is counted as failed as it has no arrival timings
+		notificationsSent.WithLabelValues("failed").Inc()
+		failedMessage := translate(language, "notification.unableToFetch", busJob.BusServiceNo, busJob.BusStopCode)
+		outgoingMessages.Push(priorityAlarm, tgbotapi.NewMessage(busJob.ChatID, failedMessage))
+		return
+	}
+	textMessage := busArrivalInformation.toMessageString(preferences)
+
+	messageToSend := tgbotapi.NewMessage(busJob.ChatID, textMessage)
+	messageToSend.ReplyMarkup = buildNotificationKeyboard(language, busJob.BusStopCode, busJob.BusServiceNo)
+	outgoingMessages.Push(priorityAlarm, arrivalNotification{messageToSend})
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Buckets of the layout before bus alarms had IDs
+const legacyUserBucket string = "users"
+const legacyJobBucket string = "jobs"
+
+// legacyBusInfoJob is how a bus alarm was stored before it had an ID, one record for each weekday
+type legacyBusInfoJob struct {
+	ChatID        int64
+	BusStopCode   string
+	BusServiceNo  string
+	ScheduledTime ScheduledTime
+	Weekday       time.Weekday
+}
+
+// Bus alarms that only differ by weekday are merged into one
+type legacyAlarmKey struct {
+	ChatID        int64
+	BusStopCode   string
+	BusServiceNo  string
+	ScheduledTime ScheduledTime
+}
+
+// migrations lists the schema changes of the job database, in order
+func (s *JobDB) migrations() []migration {
+	return []migration{
+		{version: 1, description: "Move per-weekday jobs into alarms with IDs", migrate: s.migrateLegacyJobs},
+		{version: 2, description: "Merge duplicate alarms", migrate: s.mergeDuplicateJobs},
+	}
+}
+
+// migrateLegacyJobs moves bus alarms from the legacy layout, where the users bucket holds
+// a JSON array of per-weekday jobs for each ChatID, into one record with an ID for each alarm.
+// Identical per-weekday jobs are stored only once. The legacy buckets are deleted afterwards
+func (s *JobDB) migrateLegacyJobs(tx *bolt.Tx) ([]string, error) {
+	changes := []string{}
+	b := tx.Bucket([]byte(legacyUserBucket))
+	if b == nil {
+		return changes, nil
+	}
+
+	alarms, err := mergeLegacyJobs(b)
+	if err != nil {
+		return nil, err
+	}
+
+	alarmBucket, err := tx.CreateBucketIfNotExists([]byte(s.alarmBucket))
+	if err != nil {
+		return nil, err
+	}
+	for _, alarm := range alarms {
+		alarm.ID, err = alarmBucket.NextSequence()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.putJob(alarm, tx); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("Create alarm %d: %+v", alarm.ID, alarm))
+	}
+
+	if err := tx.DeleteBucket([]byte(legacyUserBucket)); err != nil {
+		return nil, err
+	}
+	changes = append(changes, "Delete bucket "+legacyUserBucket)
+	if tx.Bucket([]byte(legacyJobBucket)) != nil {
+		if err := tx.DeleteBucket([]byte(legacyJobBucket)); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "Delete bucket "+legacyJobBucket)
+	}
+	return changes, nil
+}
+
+// mergeLegacyJobs groups the per-weekday jobs of every user into bus alarms, in the order they were first registered
+func mergeLegacyJobs(legacyUserBucket *bolt.Bucket) ([]BusInfoJob, error) {
+	alarms := []BusInfoJob{}
+	err := legacyUserBucket.ForEach(func(k []byte, v []byte) error {
+		legacyJobs := []legacyBusInfoJob{}
+		if err := json.Unmarshal(v, &legacyJobs); err != nil {
+			return fmt.Errorf("Unable to decode legacy jobs of %s: %v", k, err)
+		}
+
+		alarmIndex := make(map[legacyAlarmKey]int)
+		userAlarms := []BusInfoJob{}
+		for _, legacyJob := range legacyJobs {
+			key := legacyAlarmKey{legacyJob.ChatID, legacyJob.BusStopCode, legacyJob.BusServiceNo, legacyJob.ScheduledTime}
+			i, ok := alarmIndex[key]
+			if !ok {
+				i = len(userAlarms)
+				alarmIndex[key] = i
+				userAlarms = append(userAlarms, BusInfoJob{
+					ChatID:        legacyJob.ChatID,
+					BusStopCode:   legacyJob.BusStopCode,
+					BusServiceNo:  legacyJob.BusServiceNo,
+					ScheduledTime: legacyJob.ScheduledTime,
+				})
+			}
+			if !userAlarms[i].HasWeekday(legacyJob.Weekday) {
+				userAlarms[i].Weekdays = append(userAlarms[i].Weekdays, legacyJob.Weekday)
+			}
+		}
+
+		for _, alarm := range userAlarms {
+			sort.Slice(alarm.Weekdays, func(i, j int) bool {
+				return alarm.Weekdays[i] < alarm.Weekdays[j]
+			})
+			alarms = append(alarms, alarm)
+		}
+		return nil
+	})
+	return alarms, err
+}
+
+// mergeDuplicateJobs merges the alarms of a chat that are for the same bus at the same bus stop and time,
+// which went off once for each copy. The days of the later alarms are added to the earliest alarm, and the later alarms are deleted
+func (s *JobDB) mergeDuplicateJobs(tx *bolt.Tx) ([]string, error) {
+	changes := []string{}
+	chatIDs := tx.Bucket([]byte(s.chatIDBucket))
+	if chatIDs == nil {
+		return changes, nil
+	}
+
+	duplicates := [][]BusInfoJob{}
+	chatIDs.ForEach(func(k []byte, _ []byte) error {
+		// Bad entries are left for db fsck to report
+		jobs, _ := s.getJobsByIndex(chatIDs.Bucket(k), tx)
+		for i, job := range jobs {
+			sameAlarms := []BusInfoJob{job}
+			for _, other := range jobs[i+1:] {
+				if other.IsSameAlarm(job) {
+					sameAlarms = append(sameAlarms, other)
+				}
+			}
+			isEarliest := true
+			for _, earlier := range jobs[:i] {
+				if earlier.IsSameAlarm(job) {
+					isEarliest = false
+				}
+			}
+			if isEarliest && len(sameAlarms) > 1 {
+				duplicates = append(duplicates, sameAlarms)
+			}
+		}
+		return nil
+	})
+
+	// Buckets cannot be changed while iterating over them
+	for _, sameAlarms := range duplicates {
+		merged := sameAlarms[0]
+		for _, duplicate := range sameAlarms[1:] {
+			for _, weekday := range duplicate.Weekdays {
+				if !merged.HasWeekday(weekday) {
+					merged.Weekdays = append(merged.Weekdays, weekday)
+				}
+			}
+			if err := s.deleteJob(duplicate.ID, tx); err != nil {
+				return nil, err
+			}
+			changes = append(changes, fmt.Sprintf("Merge alarm %d into alarm %d", duplicate.ID, merged.ID))
+		}
+		sort.Slice(merged.Weekdays, func(i, j int) bool {
+			return merged.Weekdays[i] < merged.Weekdays[j]
+		})
+		if err := s.deleteJob(merged.ID, tx); err != nil {
+			return nil, err
+		}
+		if err := s.putJob(merged, tx); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
@@ -1,33 +1,318 @@
 package main
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/boltdb/bolt"
 )
 
 func TestCreateReadDeleteStoredJobs(t *testing.T) {
-	storedJobDB = NewJobDB("test.db")
-	defer os.Remove("test.db")
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testCreateReadDeleteJobs(t, NewJobDB(db))
+}
 
+func TestCreateReadDeleteMemoryJobs(t *testing.T) {
+	testCreateReadDeleteJobs(t, NewMemoryJobDB())
+}
+
+func testCreateReadDeleteJobs(t *testing.T, jobStore JobStore) {
 	timeToExecute := ScheduledTime{17, 20}
-	busInfoJob := BusInfoJob{12345, "43411", "506", timeToExecute, time.Monday}
+	busInfoJob := BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: timeToExecute, Weekdays: []time.Weekday{time.Monday, time.Friday}}
 
-	storedJobDB.StoreJob(busInfoJob)
+	storedJob, err := jobStore.StoreJob(busInfoJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedJob.ID == 0 {
+		t.Errorf("Bus info job should be assigned an ID")
+	}
 
-	storedJobs := storedJobDB.GetJobsByChatID(12345)
-	if len(storedJobs) != 1 || storedJobs[0].BusStopCode != "43411" || storedJobs[0].BusServiceNo != "506" || storedJobs[0].ScheduledTime != timeToExecute || storedJobs[0].Weekday != time.Monday {
+	storedJobs, _ := jobStore.GetJobsByChatID(12345)
+	if len(storedJobs) != 1 || storedJobs[0].ID != storedJob.ID || storedJobs[0].BusStopCode != "43411" || storedJobs[0].BusServiceNo != "506" || storedJobs[0].ScheduledTime != timeToExecute || len(storedJobs[0].Weekdays) != 2 {
 		t.Errorf("Bus info job not stored correctly")
 	}
 
-	storedJobDB.DeleteJob(busInfoJob)
+	for _, day := range []time.Weekday{time.Monday, time.Friday} {
+		storedJobsByDay, _ := jobStore.GetJobsByDay(day)
+		if len(storedJobsByDay) != 1 || storedJobsByDay[0].ID != storedJob.ID {
+			t.Errorf("Bus info job not found on %s", day)
+		}
+	}
+	if storedJobsByDay, _ := jobStore.GetJobsByDay(time.Tuesday); len(storedJobsByDay) != 0 {
+		t.Errorf("Bus info job should not be found on Tuesday")
+	}
+	if allJobs, _ := jobStore.GetAllJobs(); len(allJobs) != 1 || allJobs[0].ID != storedJob.ID {
+		t.Errorf("Bus info job not found in all jobs")
+	}
+
+	movedJobs, err := jobStore.MoveJobs(12345, -100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movedJobs) != 1 || movedJobs[0].ID != storedJob.ID || movedJobs[0].ChatID != -100 {
+		t.Errorf("Unexpected moved jobs: %v", movedJobs)
+	}
+	if storedJobs, _ := jobStore.GetJobsByChatID(12345); len(storedJobs) != 0 {
+		t.Errorf("Bus info job should no longer belong to the old chat")
+	}
+	if storedJobsByDay, _ := jobStore.GetJobsByDay(time.Monday); len(storedJobsByDay) != 1 || storedJobsByDay[0].ChatID != -100 {
+		t.Errorf("Bus info job should belong to the new chat on Monday")
+	}
 
-	storedJobsByChatID := storedJobDB.GetJobsByChatID(12345)
-	storedJobsByDay := storedJobDB.GetJobsByDay(time.Monday)
+	if err := jobStore.DeleteJob(storedJob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	storedJobsByChatID, _ := jobStore.GetJobsByChatID(-100)
+	storedJobsByDay, _ := jobStore.GetJobsByDay(time.Monday)
 	if len(storedJobsByChatID) > 0 || len(storedJobsByDay) > 0 {
 		log.Println("storedJobsByChatID: {}", storedJobsByChatID)
 		log.Println("storedJobsByDay: {}", storedJobsByDay)
 		t.Errorf("Bus info job not deleted correctly")
 	}
 }
+
+func TestMigrateLegacyJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	timeToExecute := ScheduledTime{7, 30}
+	legacyJobs := []legacyBusInfoJob{
+		{12345, "43411", "506", timeToExecute, time.Monday},
+		{12345, "43411", "506", timeToExecute, time.Tuesday},
+		{12345, "43411", "506", timeToExecute, time.Tuesday},
+		{12345, "43411", "506", ScheduledTime{18, 0}, time.Monday},
+	}
+	db.Update(func(tx *bolt.Tx) error {
+		users, _ := tx.CreateBucket([]byte(legacyUserBucket))
+		encLegacyJobs, _ := json.Marshal(legacyJobs)
+		users.Put([]byte("12345"), encLegacyJobs)
+		jobs, _ := tx.CreateBucket([]byte(legacyJobBucket))
+		jobs.Put([]byte("Monday"), []byte("[12345]"))
+		jobs.Put([]byte("Tuesday"), []byte("[12345]"))
+		return nil
+	})
+
+	jobDB := NewJobDB(db)
+	reports, err := runMigrations(db, jobDB.migrations(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || len(reports[0].changes) != 4 || len(reports[1].changes) != 0 {
+		t.Errorf("Dry run should report 2 created alarms and 2 deleted buckets: %+v", reports)
+	}
+	if storedJobs, _ := jobDB.GetJobsByChatID(12345); len(storedJobs) != 0 {
+		t.Errorf("Dry run should not change the database")
+	}
+
+	if _, err := runMigrations(db, jobDB.migrations(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	storedJobs, _ := jobDB.GetJobsByChatID(12345)
+	if len(storedJobs) != 2 {
+		t.Fatalf("Expected 2 alarms after migration but got %d", len(storedJobs))
+	}
+	if storedJobs[0].ScheduledTime != timeToExecute || len(storedJobs[0].Weekdays) != 2 || storedJobs[0].Weekdays[0] != time.Monday || storedJobs[0].Weekdays[1] != time.Tuesday {
+		t.Errorf("Per-weekday jobs not merged correctly: %+v", storedJobs[0])
+	}
+	mondayJobs, _ := jobDB.GetJobsByDay(time.Monday)
+	tuesdayJobs, _ := jobDB.GetJobsByDay(time.Tuesday)
+	if len(mondayJobs) != 2 || len(tuesdayJobs) != 1 {
+		t.Errorf("Migrated alarms not found by day")
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(legacyUserBucket)) != nil || tx.Bucket([]byte(legacyJobBucket)) != nil {
+			t.Errorf("Legacy buckets should be deleted after migration")
+		}
+		if version, _ := getSchemaVersion(tx); version != 2 {
+			t.Errorf("Schema version should be 2 but is %d", version)
+		}
+		return nil
+	})
+}
+
+func TestMemoryUserStateIsNotChangedByCaller(t *testing.T) {
+	userStateStore := NewMemoryUserStateDB()
+	userStateStore.SaveUserState(12345, UserState{State: 3, SelectedDays: make(map[time.Weekday]bool)})
+
+	userState, _ := userStateStore.GetUserState(12345)
+	userState.ToggleDay(time.Monday)
+
+	if storedUserState, _ := userStateStore.GetUserState(12345); len(storedUserState.GetSelectedDays()) != 0 {
+		t.Errorf("Stored user state should only change when saved")
+	}
+
+	userStateStore.DeleteUserState(12345)
+	if storedUserState, _ := userStateStore.GetUserState(12345); storedUserState != nil {
+		t.Errorf("User state not deleted correctly")
+	}
+}
+
+func TestStoreOrMergeStoredJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testStoreOrMergeJobs(t, NewJobDB(db))
+}
+
+func TestBadEntriesAreSkippedWhenStoringAndMoving(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	jobDB := NewJobDB(db)
+	alarm := BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}}
+	storedJob, _ := jobDB.StoreJob(alarm)
+	// A deleted alarm is left behind in the chat's index
+	db.Update(func(tx *bolt.Tx) error {
+		return nestedBucket(tx, jobDB.chatIDBucket, chatIDKey(12345)).Put(alarmKey(99), []byte{})
+	})
+
+	alarm.Weekdays = []time.Weekday{time.Friday}
+	mergedJob, _, err := storeOrMergeJob(jobDB, alarm)
+	if err != nil || mergedJob.ID != storedJob.ID || !mergedJob.HasWeekday(time.Friday) {
+		t.Errorf("Expected the alarm to be merged despite the bad entry but got %v, %v", mergedJob, err)
+	}
+
+	movedJobs, err := jobDB.MoveJobs(12345, -100)
+	if err != nil || len(movedJobs) != 1 || movedJobs[0].ID != storedJob.ID {
+		t.Errorf("Expected the alarm to move despite the bad entry but got %v, %v", movedJobs, err)
+	}
+}
+
+func TestStoreOrMergeMemoryJobs(t *testing.T) {
+	testStoreOrMergeJobs(t, NewMemoryJobDB())
+}
+
+func testStoreOrMergeJobs(t *testing.T, jobStore JobStore) {
+	alarm := BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}}
+
+	alarm.Weekdays = []time.Weekday{time.Monday, time.Friday}
+	storedJob, coveredDays, err := storeOrMergeJob(jobStore, alarm)
+	if err != nil || len(coveredDays) != 0 {
+		t.Fatalf("Expected a new alarm but got %v, %v", coveredDays, err)
+	}
+
+	alarm.Weekdays = []time.Weekday{time.Monday, time.Tuesday}
+	mergedJob, coveredDays, err := storeOrMergeJob(jobStore, alarm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mergedJob.ID != storedJob.ID || len(coveredDays) != 1 || coveredDays[0] != time.Monday {
+		t.Errorf("Expected Monday to be covered by alarm %d but got %v covering %v", storedJob.ID, mergedJob, coveredDays)
+	}
+	jobs, _ := jobStore.GetJobsByChatID(12345)
+	if len(jobs) != 1 || len(jobs[0].Weekdays) != 3 || jobs[0].Weekdays[0] != time.Monday || jobs[0].Weekdays[1] != time.Tuesday || jobs[0].Weekdays[2] != time.Friday {
+		t.Errorf("Expected one alarm on Monday, Tuesday & Friday but got %v", jobs)
+	}
+	if tuesdayJobs, _ := jobStore.GetJobsByDay(time.Tuesday); len(tuesdayJobs) != 1 || tuesdayJobs[0].ID != storedJob.ID {
+		t.Errorf("Merged alarm not found on Tuesday")
+	}
+
+	alarm.Weekdays = []time.Weekday{time.Friday}
+	if _, coveredDays, _ := storeOrMergeJob(jobStore, alarm); len(coveredDays) != 1 {
+		t.Errorf("Expected the alarm to be a duplicate but got %v covered", coveredDays)
+	}
+	if mondayJobs, _ := jobStore.GetJobsByDay(time.Monday); len(mondayJobs) != 1 {
+		t.Errorf("Expected the alarm to go off once on Monday but got %v", mondayJobs)
+	}
+
+	alarm.ScheduledTime = ScheduledTime{18, 0}
+	if otherJob, coveredDays, _ := storeOrMergeJob(jobStore, alarm); otherJob.ID == storedJob.ID || len(coveredDays) != 0 {
+		t.Errorf("Expected an alarm at another time to be stored separately but got %v", otherJob)
+	}
+
+	if err := jobStore.UpdateJob(BusInfoJob{ID: 999, ChatID: 12345}); err == nil {
+		t.Errorf("Expected a missing alarm not to be updated")
+	}
+}
+
+func TestMergeDuplicateJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bus-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	jobDB := NewJobDB(db)
+	alarm := BusInfoJob{ChatID: 12345, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}}
+	db.Update(func(tx *bolt.Tx) error {
+		for i, weekdays := range [][]time.Weekday{{time.Monday, time.Friday}, {time.Monday}, {time.Wednesday}} {
+			alarm.ID = uint64(i + 1)
+			alarm.Weekdays = weekdays
+			jobDB.putJob(alarm, tx)
+		}
+		jobDB.putJob(BusInfoJob{ID: 4, ChatID: 54321, BusStopCode: "43411", BusServiceNo: "506", ScheduledTime: ScheduledTime{7, 30}, Weekdays: []time.Weekday{time.Monday}}, tx)
+		return setSchemaVersion(tx, 1)
+	})
+
+	reports, err := runMigrations(db, jobDB.migrations(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || len(reports[0].changes) != 2 {
+		t.Errorf("Expected 2 alarms to be merged: %+v", reports)
+	}
+
+	jobs, _ := jobDB.GetJobsByChatID(12345)
+	if len(jobs) != 1 || jobs[0].ID != 1 || len(jobs[0].Weekdays) != 3 || jobs[0].Weekdays[1] != time.Wednesday {
+		t.Errorf("Expected one alarm on Monday, Wednesday & Friday but got %v", jobs)
+	}
+	if mondayJobs, _ := jobDB.GetJobsByDay(time.Monday); len(mondayJobs) != 2 {
+		t.Errorf("Expected one alarm of each chat on Monday but got %v", mondayJobs)
+	}
+	if wednesdayJobs, _ := jobDB.GetJobsByDay(time.Wednesday); len(wednesdayJobs) != 1 || wednesdayJobs[0].ID != 1 {
+		t.Errorf("Expected the merged alarm on Wednesday but got %v", wednesdayJobs)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// Preferences contains how a user wants bus arrival information to be displayed, the language to use,
+// and in groups, who can manage the group's alarms. The zero value is the default.
+// Language is chosen with /language, LanguageCode is the language of the user's Telegram app
+type Preferences struct {
+	Verbose                bool
+	ClockTime              bool
+	HideBusStopDescription bool
+	AdminsOnly             bool
+	Language               string
+	LanguageCode           string
+}
+
+// PreferencesDB contains the operations to store/retrieve user preferences
+type PreferencesDB struct {
+	db                *bolt.DB
+	preferencesBucket string
+}
+
+// NewPreferencesDB returns an initialised instance of PreferencesDB
+func NewPreferencesDB(db *bolt.DB) *PreferencesDB {
+	return &PreferencesDB{db: db, preferencesBucket: "preferences"}
+}
+
+// migrations lists the schema changes of the preferences database, in order
+func (s *PreferencesDB) migrations() []migration {
+	return []migration{
+		{version: 1, description: "Create preferences bucket", migrate: func(tx *bolt.Tx) ([]string, error) {
+			return createBucketMigration(tx, s.preferencesBucket)
+		}},
+	}
+}
+
+// GetPreferences retrieves the stored preferences, returning the default preferences if there are none
+func (s *PreferencesDB) GetPreferences(chatID int64) (Preferences, error) {
+	key := []byte(strconv.FormatInt(chatID, 10))
+	var storedPreferences Preferences
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.preferencesBucket))
+		if b == nil {
+			return nil
+		}
+		storedValue := b.Get(key)
+		if storedValue == nil {
+			return nil
+		}
+		if err := json.Unmarshal(storedValue, &storedPreferences); err != nil {
+			return fmt.Errorf("Unable to decode preferences of %d: %v", chatID, err)
+		}
+		return nil
+	})
+
+	return storedPreferences, err
+}
+
+// SavePreferences saves the user's preferences
+func (s *PreferencesDB) SavePreferences(chatID int64, preferences Preferences) error {
+	logDebug("Saving preferences", "chat_id", chatID, "preferences", fmt.Sprintf("%+v", preferences))
+
+	key := []byte(strconv.FormatInt(chatID, 10))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(s.preferencesBucket))
+		if err != nil {
+			return err
+		}
+
+		encPreferences, err := json.Marshal(preferences)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, encPreferences)
+	})
+}
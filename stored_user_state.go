@@ -2,8 +2,7 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
-	"log"
+	"fmt"
 	"sort"
 	"strconv"
 	"time"
@@ -18,10 +17,15 @@ import (
 // 3 (user asked about which days, can self loop)
 // 4 (user asked about what time)
 // 5 (user asked which alarm to delete)
+// 6 (user asked to confirm the alarms from an uploaded export)
+// In groups, the state belongs to the whole chat and only the initiator, the user who started the registration, can answer
 type UserState struct {
 	State int
 	BusInfoJob
-	SelectedDays map[time.Weekday]bool
+	SelectedDays  map[time.Weekday]bool
+	ImportedJobs  []BusInfoJob
+	InitiatorID   int
+	InitiatorName string
 }
 
 // ToggleDay toggles the truthy selection of the day
@@ -47,87 +51,81 @@ func (userState *UserState) GetSelectedDays() []time.Weekday {
 // UserStateDB contains the operations to store/retrieve/delete user states,
 // holding information about the stage of registration that the user is at
 type UserStateDB struct {
-	dbFile       string
+	db           *bolt.DB
 	statesBucket string
 }
 
 // NewUserStateDB returns an initialised instance of UserStateDB
-func NewUserStateDB(dbFile string) UserStateDB {
-	return UserStateDB{dbFile: dbFile, statesBucket: "users"}
+func NewUserStateDB(db *bolt.DB) *UserStateDB {
+	return &UserStateDB{db: db, statesBucket: "users"}
 }
 
-// GetUserState retrieves the stored user state
-func (s *UserStateDB) GetUserState(chatID int64) *UserState {
-	key := []byte(strconv.FormatInt(chatID, 10))
-	var storedUserState UserState
-
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
+// migrations lists the schema changes of the user state database, in order
+func (s *UserStateDB) migrations() []migration {
+	return []migration{
+		{version: 1, description: "Create user state bucket", migrate: func(tx *bolt.Tx) ([]string, error) {
+			return createBucketMigration(tx, s.statesBucket)
+		}},
 	}
-	defer db.Close()
+}
 
-	err = db.View(func(tx *bolt.Tx) error {
+// GetUserState retrieves the stored user state, nil if the user is not registering
+func (s *UserStateDB) GetUserState(chatID int64) (*UserState, error) {
+	key := []byte(strconv.FormatInt(chatID, 10))
+	var storedUserState *UserState
+
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(s.statesBucket))
 		if b == nil {
-			return errors.New("Bucket does not exist")
+			return nil
 		}
 		storedValue := b.Get(key)
 		if storedValue == nil {
-			return errors.New("Key does not exist")
+			return nil
+		}
+		storedUserState = &UserState{}
+		if err := json.Unmarshal(storedValue, storedUserState); err != nil {
+			return fmt.Errorf("Unable to decode user state of %d: %v", chatID, err)
 		}
-		json.Unmarshal(storedValue, &storedUserState)
 		return nil
 	})
 
-	// If there's no matching record in database
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return &storedUserState
+	return storedUserState, nil
 }
 
 // SaveUserState saves the user state,
-func (s *UserStateDB) SaveUserState(chatID int64, userState UserState) {
+func (s *UserStateDB) SaveUserState(chatID int64, userState UserState) error {
 	userState.ChatID = chatID
-	log.Println("Saving user interaction state:", userState)
+	logDebug("Saving user state", "chat_id", chatID, "state", userState.State)
 
 	key := []byte(strconv.FormatInt(chatID, 10))
 
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.Update(func(tx *bolt.Tx) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(s.statesBucket))
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
 
 		encUserState, err := json.Marshal(userState)
-		b.Put(key, encUserState)
-		return nil
+		if err != nil {
+			return err
+		}
+		return b.Put(key, encUserState)
 	})
 }
 
 // DeleteUserState deletes the saved user state
-func (s *UserStateDB) DeleteUserState(chatID int64) {
+func (s *UserStateDB) DeleteUserState(chatID int64) error {
 	key := []byte(strconv.FormatInt(chatID, 10))
 
-	db, err := bolt.Open(s.dbFile, 0600, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	db.Update(func(tx *bolt.Tx) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(s.statesBucket))
 		if b == nil {
-			log.Fatalln("Bucket should exist but doesn't exist")
+			return nil
 		}
-		b.Delete(key)
-		return nil
+		return b.Delete(key)
 	})
 }
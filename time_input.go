@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Callback data of the time picker's buttons
+const (
+	// Picks a time, followed by the time as hhmm
+	timeCallbackPrefix string = "time:"
+	// Shows the minutes of an hour, followed by the hour as hh
+	timeHourCallbackPrefix string = "time-hour:"
+	// Goes back to the hours
+	timeHoursCallback string = "time-hours"
+)
+
+// Number of the user's recently used times that the time picker offers
+const maxRecentTimes int = 4
+
+// Minutes between the time picker's minute buttons
+const timePickerMinuteStep int = 5
+
+// timeInputPattern matches "7", "07", "7:30", "7.30", "19h30" & "19h", and "730" & "0730" without a separator
+var timeInputPattern = regexp.MustCompile(`^(?:(\d{1,2})(?:([:.h])(\d{2})?)?|(\d{1,2})(\d{2}))$`)
+
+// parseScheduledTime reads the time that the user typed, such as "0730", "7:30", "7.30", "7:30pm", "7pm" or "19h30".
+// Times that could be in the morning or the evening, such as "7:30", return both readings with the morning first,
+// so that the user can be asked which one they meant
+func parseScheduledTime(text string) ([]ScheduledTime, error) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), ""))
+	meridiem := ""
+	for _, suffix := range []string{"a.m.", "p.m.", "am", "pm"} {
+		if strings.HasSuffix(text, suffix) {
+			meridiem = suffix[:1]
+			text = strings.TrimSuffix(text, suffix)
+			break
+		}
+	}
+
+	match := timeInputPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a time", text)
+	}
+	hourText, separator, minuteText := match[1], match[2], match[3]
+	// "0730" and "19h30" are on the 24-hour clock, as are hours with a leading zero
+	twentyFourHour := strings.HasPrefix(hourText, "0") || separator == "h"
+	if hourText == "" {
+		hourText, minuteText = match[4], match[5]
+		twentyFourHour = len(hourText) == 2
+	}
+	if minuteText == "" && separator != "" && separator != "h" {
+		return nil, fmt.Errorf("%q has no minutes", text)
+	}
+	hour, _ := strconv.Atoi(hourText)
+	minute := 0
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	if minute > 59 {
+		return nil, fmt.Errorf("%q has more than 59 minutes", text)
+	}
+
+	switch {
+	case meridiem != "":
+		if hour < 1 || hour > 12 {
+			return nil, fmt.Errorf("%q is not an hour of the 12-hour clock", hourText)
+		}
+		hour = hour % 12
+		if meridiem == "p" {
+			hour += 12
+		}
+		return []ScheduledTime{{Hour: hour, Minute: minute}}, nil
+	case hour > 23:
+		return nil, fmt.Errorf("%q has more than 23 hours", text)
+	case hour >= 1 && hour <= 12 && !twentyFourHour:
+		return []ScheduledTime{{Hour: hour, Minute: minute}, {Hour: (hour + 12) % 24, Minute: minute}}, nil
+	default:
+		return []ScheduledTime{{Hour: hour, Minute: minute}}, nil
+	}
+}
+
+// timeCallbackData returns the callback data of a button that picks the time
+func timeCallbackData(scheduledTime ScheduledTime) string {
+	return fmt.Sprintf("%s%02d%02d", timeCallbackPrefix, scheduledTime.Hour, scheduledTime.Minute)
+}
+
+// parseTimeCallbackData returns the time picked by a button from timeCallbackData
+func parseTimeCallbackData(data string) (ScheduledTime, error) {
+	if !strings.HasPrefix(data, timeCallbackPrefix) {
+		return ScheduledTime{}, fmt.Errorf("%q does not pick a time", data)
+	}
+	readings, err := parseScheduledTime(strings.TrimPrefix(data, timeCallbackPrefix))
+	if err != nil {
+		return ScheduledTime{}, err
+	}
+	if len(readings) != 1 {
+		return ScheduledTime{}, fmt.Errorf("%q picks more than one time", data)
+	}
+	return readings[0], nil
+}
+
+// parseTimeHourCallbackData returns the hour picked by a button of the time picker
+func parseTimeHourCallbackData(data string) (int, error) {
+	hour, err := strconv.Atoi(strings.TrimPrefix(data, timeHourCallbackPrefix))
+	if err != nil || !strings.HasPrefix(data, timeHourCallbackPrefix) || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("%q does not pick an hour", data)
+	}
+	return hour, nil
+}
+
+// recentTimes returns the distinct times of the alarms, from the most recently registered alarm
+func recentTimes(jobs []BusInfoJob) []ScheduledTime {
+	sortedJobs := make([]BusInfoJob, len(jobs))
+	copy(sortedJobs, jobs)
+	sort.Slice(sortedJobs, func(i, j int) bool { return sortedJobs[i].ID > sortedJobs[j].ID })
+
+	times := []ScheduledTime{}
+	seen := make(map[ScheduledTime]bool)
+	for _, job := range sortedJobs {
+		if len(times) == maxRecentTimes {
+			break
+		}
+		if !seen[job.ScheduledTime] {
+			seen[job.ScheduledTime] = true
+			times = append(times, job.ScheduledTime)
+		}
+	}
+	return times
+}
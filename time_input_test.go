@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestParseScheduledTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []ScheduledTime
+	}{
+		{"0730", []ScheduledTime{{7, 30}}},
+		{"1930", []ScheduledTime{{19, 30}}},
+		{"07:30", []ScheduledTime{{7, 30}}},
+		{"19:30", []ScheduledTime{{19, 30}}},
+		{"19.30", []ScheduledTime{{19, 30}}},
+		{"19h30", []ScheduledTime{{19, 30}}},
+		{"7h30", []ScheduledTime{{7, 30}}},
+		{"19h", []ScheduledTime{{19, 0}}},
+		{"0:15", []ScheduledTime{{0, 15}}},
+		{"00", []ScheduledTime{{0, 0}}},
+		{"7:30pm", []ScheduledTime{{19, 30}}},
+		{"7.30am", []ScheduledTime{{7, 30}}},
+		{"7.30 a.m.", []ScheduledTime{{7, 30}}},
+		{" 7pm ", []ScheduledTime{{19, 0}}},
+		{"7 PM", []ScheduledTime{{19, 0}}},
+		{"12am", []ScheduledTime{{0, 0}}},
+		{"12:15pm", []ScheduledTime{{12, 15}}},
+		{"7:30", []ScheduledTime{{7, 30}, {19, 30}}},
+		{"7.30", []ScheduledTime{{7, 30}, {19, 30}}},
+		{"730", []ScheduledTime{{7, 30}, {19, 30}}},
+		{"7", []ScheduledTime{{7, 0}, {19, 0}}},
+		{"12", []ScheduledTime{{12, 0}, {0, 0}}},
+		{"10:45", []ScheduledTime{{10, 45}, {22, 45}}},
+		{"", nil},
+		{"7:", nil},
+		{"7.", nil},
+		{"7:3", nil},
+		{"24:00", nil},
+		{"2400", nil},
+		{"7:60", nil},
+		{"13pm", nil},
+		{"0am", nil},
+		{"19:30:00", nil},
+		{"half past seven", nil},
+		{"12345", nil},
+	}
+	for _, test := range tests {
+		readings, err := parseScheduledTime(test.input)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("Expected %q to be invalid but got %v", test.input, readings)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected %q to be %v but got %v", test.input, test.expected, err)
+		} else if !reflect.DeepEqual(readings, test.expected) {
+			t.Errorf("Expected %q to be %v but got %v", test.input, test.expected, readings)
+		}
+	}
+}
+
+func TestTimeCallbackData(t *testing.T) {
+	for _, scheduledTime := range []ScheduledTime{{0, 30}, {7, 5}, {12, 0}, {19, 30}} {
+		parsed, err := parseTimeCallbackData(timeCallbackData(scheduledTime))
+		if err != nil || parsed != scheduledTime {
+			t.Errorf("Expected %v to be picked but got %v, %v", scheduledTime, parsed, err)
+		}
+	}
+	if _, err := parseTimeCallbackData("1"); err == nil {
+		t.Errorf("Expected a weekday button not to pick a time")
+	}
+}
+
+func TestRecentTimes(t *testing.T) {
+	jobs := []BusInfoJob{
+		{ID: 1, ScheduledTime: ScheduledTime{7, 30}},
+		{ID: 4, ScheduledTime: ScheduledTime{18, 0}},
+		{ID: 2, ScheduledTime: ScheduledTime{8, 0}},
+		{ID: 3, ScheduledTime: ScheduledTime{7, 30}},
+		{ID: 5, ScheduledTime: ScheduledTime{9, 15}},
+		{ID: 6, ScheduledTime: ScheduledTime{12, 0}},
+	}
+	expected := []ScheduledTime{{12, 0}, {9, 15}, {18, 0}, {7, 30}}
+	if times := recentTimes(jobs); !reflect.DeepEqual(times, expected) {
+		t.Errorf("Expected %v but got %v", expected, times)
+	}
+	if jobs[0].ID != 1 {
+		t.Errorf("The alarms should not be reordered")
+	}
+}
+
+func TestTimePickerKeyboards(t *testing.T) {
+	hourKeyboard := buildHourKeyboard([]ScheduledTime{{7, 30}})
+	if len(hourKeyboard.InlineKeyboard) != 5 || *hourKeyboard.InlineKeyboard[0][0].CallbackData != "time:0730" {
+		t.Errorf("Expected the recent time above 4 rows of hours but got %v", hourKeyboard.InlineKeyboard)
+	}
+	hourButton := hourKeyboard.InlineKeyboard[2][1]
+	hour, err := parseTimeHourCallbackData(*hourButton.CallbackData)
+	if hourButton.Text != "07" || err != nil || hour != 7 {
+		t.Errorf("Expected the button of 07 to pick hour 7 but got %v, %v", hour, err)
+	}
+	if hourKeyboard := buildHourKeyboard(nil); len(hourKeyboard.InlineKeyboard) != 4 {
+		t.Errorf("Expected only the hours without recent times but got %v", hourKeyboard.InlineKeyboard)
+	}
+
+	minuteKeyboard := buildMinuteKeyboard(defaultLanguage, 7)
+	var minuteButtons []tgbotapi.InlineKeyboardButton
+	for _, row := range minuteKeyboard.InlineKeyboard[:len(minuteKeyboard.InlineKeyboard)-1] {
+		minuteButtons = append(minuteButtons, row...)
+	}
+	if len(minuteButtons) != 12 || minuteButtons[0].Text != "07:00" || minuteButtons[11].Text != "07:55" {
+		t.Errorf("Expected a button for every 5 minutes but got %v", minuteButtons)
+	}
+	if scheduledTime, _ := parseTimeCallbackData(*minuteButtons[9].CallbackData); scheduledTime != (ScheduledTime{7, 45}) {
+		t.Errorf("Expected 07:45 to be picked but got %v", scheduledTime)
+	}
+	backButton := minuteKeyboard.InlineKeyboard[len(minuteKeyboard.InlineKeyboard)-1][0]
+	if *backButton.CallbackData != timeHoursCallback {
+		t.Errorf("Expected a button back to the hours but got %v", backButton)
+	}
+	if _, err := parseTimeHourCallbackData("time-hour:24"); err == nil {
+		t.Errorf("Expected hour 24 not to be picked")
+	}
+}
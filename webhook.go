@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Telegram sends the secret token given in setWebhook in this header of every update
+const webhookSecretTokenHeader string = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookHandler receives updates that Telegram posts to the webhook,
+// and passes them into the same channel that long polling would
+type webhookHandler struct {
+	secretToken string
+	updates     chan tgbotapi.Update
+}
+
+func newWebhookHandler(secretToken string, bufferSize int) *webhookHandler {
+	return &webhookHandler{secretToken: secretToken, updates: make(chan tgbotapi.Update, bufferSize)}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	secretToken := r.Header.Get(webhookSecretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(secretToken), []byte(h.secretToken)) != 1 {
+		logWarn("Rejected webhook request with invalid secret token", "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		logWarn("Unable to decode webhook update", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.updates <- update
+	recordUpdatesSuccess()
+	w.WriteHeader(http.StatusOK)
+}
+
+// setWebhook registers the webhook URL and secret token with Telegram
+func setWebhook(bot *tgbotapi.BotAPI, webhookURL string, secretToken string) error {
+	v := url.Values{}
+	v.Add("url", webhookURL)
+	v.Add("secret_token", secretToken)
+	_, err := bot.MakeRequest("setWebhook", v)
+	return err
+}
+
+// webhookPath returns the path that the webhook is served on, the root path if the webhook URL has none
+func webhookPath(webhookURL string) (string, error) {
+	parsedURL, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", err
+	}
+	if parsedURL.Path == "" {
+		return "/", nil
+	}
+	return parsedURL.Path, nil
+}
+
+// listenForWebhook registers the webhook with Telegram and serves it on listenAddress,
+// returning the channel that received updates are passed into, and the server to shut down when the bot stops
+func listenForWebhook(bot *tgbotapi.BotAPI, listenAddress string, webhookURL string, secretToken string) (tgbotapi.UpdatesChannel, *http.Server, error) {
+	if secretToken == "" {
+		return nil, nil, errors.New("Webhook secret token must be set")
+	}
+	path, err := webhookPath(webhookURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := setWebhook(bot, webhookURL, secretToken); err != nil {
+		return nil, nil, err
+	}
+
+	handler := newWebhookHandler(secretToken, bot.Buffer)
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+
+	server := &http.Server{Addr: listenAddress, Handler: mux}
+	go func() {
+		logInfo("Listening for webhook", "address", listenAddress, "path", path)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			logFatal("Unable to serve webhook", "err", err)
+		}
+	}()
+	return handler.updates, server, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const recordedUpdate string = `{"update_id":10000,"message":{"message_id":1365,"date":1441645532,"chat":{"id":1111111,"type":"private","first_name":"Test"},"from":{"id":1111111,"first_name":"Test"},"text":"/register","entities":[{"type":"bot_command","offset":0,"length":9}]}}`
+
+func TestWebhookPassesRecordedUpdate(t *testing.T) {
+	handler := newWebhookHandler("secret", 1)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(recordedUpdate))
+	req.Header.Set(webhookSecretTokenHeader, "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 but got %d", res.StatusCode)
+	}
+
+	update := <-handler.updates
+	if update.UpdateID != 10000 || update.Message.Chat.ID != 1111111 || update.Message.Command() != "register" {
+		t.Errorf("Update not decoded correctly: %+v", update)
+	}
+}
+
+func TestWebhookRejectsInvalidSecretToken(t *testing.T) {
+	handler := newWebhookHandler("secret", 1)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(recordedUpdate))
+	req.Header.Set(webhookSecretTokenHeader, "wrong")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 but got %d", res.StatusCode)
+	}
+	if len(handler.updates) != 0 {
+		t.Errorf("Update with invalid secret token should not be passed on")
+	}
+}
+
+func TestWebhookPathDefaultsToRoot(t *testing.T) {
+	for webhookURL, expected := range map[string]string{"https://example.com": "/", "https://example.com/telegram/webhook": "/telegram/webhook"} {
+		if path, err := webhookPath(webhookURL); err != nil || path != expected {
+			t.Errorf("Expected %s to be served on %s but got %s, %v", webhookURL, expected, path, err)
+		}
+	}
+}